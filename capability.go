@@ -43,6 +43,16 @@ func ParseCapabilities(s string) Capabilities {
 	return caps
 }
 
+// Contains returns true if the capabilities contain the provided capability.
+func (caps Capabilities) Contains(capability Capability) bool {
+	for _, c := range caps {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
 // Equal determines if one set of capabilities matches another.
 func (caps Capabilities) Equal(other Capabilities) bool {
 	if len(caps) != len(other) {
@@ -67,3 +77,37 @@ func (caps Capabilities) String() string {
 	}
 	return CapSep + strings.Join(ss, CapSep) + CapSep
 }
+
+// InferCapabilities infers the capabilities of a client
+// from the optional interfaces its SessionHandler implements.
+func InferCapabilities(h SessionHandler) Capabilities {
+	caps := Capabilities{}
+	if _, ok := h.(Switcher); ok {
+		caps = append(caps, CapClientSwitch)
+	}
+	if _, ok := h.(DirtyNotifier); ok {
+		caps = append(caps, CapClientDirty)
+	}
+	if implementsProgress(h) {
+		caps = append(caps, CapClientProgress)
+	}
+	if _, ok := h.(StatusNotifier); ok {
+		caps = append(caps, CapClientMessage)
+	}
+	if _, ok := h.(GUIController); ok {
+		caps = append(caps, CapGUI)
+	}
+	return caps
+}
+
+// implementsProgress returns true if a handler reports progress.
+func implementsProgress(h SessionHandler) bool {
+	if _, ok := h.(ProgressNotifier); ok {
+		return true
+	}
+	if _, ok := h.(ProgressOpener); ok {
+		return true
+	}
+	_, ok := h.(ProgressSaver)
+	return ok
+}
@@ -57,6 +57,17 @@ func TestCapabilitiesEqual(t *testing.T) {
 	}
 }
 
+func TestCapabilitiesContains(t *testing.T) {
+	caps := Capabilities{CapClientSwitch, CapClientDirty}
+
+	if !caps.Contains(CapClientDirty) {
+		t.Fatalf("expected %s to contain %s", caps, CapClientDirty)
+	}
+	if caps.Contains(CapClientProgress) {
+		t.Fatalf("expected %s to not contain %s", caps, CapClientProgress)
+	}
+}
+
 func TestCapabilitiesString(t *testing.T) {
 	for i, testcase := range []struct {
 		input    Capabilities
@@ -76,3 +87,34 @@ func TestCapabilitiesString(t *testing.T) {
 		}
 	}
 }
+
+type guiHandler struct{}
+
+func (h guiHandler) Open(info SessionInfo) (string, Error) { return "", nil }
+func (h guiHandler) Save() (string, Error)                 { return "", nil }
+func (h guiHandler) ShowGUI(show bool) error               { return nil }
+func (h guiHandler) Dirty() chan bool                      { return nil }
+
+func TestInferCapabilities(t *testing.T) {
+	for i, testcase := range []struct {
+		input    SessionHandler
+		expected Capabilities
+	}{
+		{
+			input:    &ExampleClient{},
+			expected: Capabilities{CapClientDirty, CapClientProgress, CapClientMessage, CapGUI},
+		},
+		{
+			input:    guiHandler{},
+			expected: Capabilities{CapClientDirty, CapGUI},
+		},
+		{
+			input:    &switchHandler{},
+			expected: Capabilities{CapClientSwitch},
+		},
+	} {
+		if expected, got := testcase.expected, InferCapabilities(testcase.input); !expected.Equal(got) {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
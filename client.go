@@ -4,7 +4,7 @@ import (
 	"context"
 	"net"
 	"os"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -27,10 +27,12 @@ const (
 	AddressClientSessionIsLoaded = "/nsm/client/session_is_loaded"
 	AddressClientStatus          = "/nsm/client/message"
 	AddressError                 = "/error"
+	AddressPing                  = "/osc/ping"
 	AddressReply                 = "/reply"
 	AddressServerAbort           = "/nsm/server/abort"
 	AddressServerAdd             = "/nsm/server/add"
 	AddressServerAnnounce        = "/nsm/server/announce"
+	AddressServerBroadcast       = "/nsm/server/broadcast"
 	AddressServerClients         = "/nsm/server/clients"
 	AddressServerClose           = "/nsm/server/close"
 	AddressServerDuplicate       = "/nsm/server/duplicate"
@@ -46,6 +48,14 @@ const (
 // NsmURL is the name of the NSM url environment variable.
 var NsmURL = "NSM_URL"
 
+// DefaultProgressInterval is the default minimum amount of time
+// between two progress messages sent by a Progress.
+var DefaultProgressInterval = 100 * time.Millisecond
+
+// DefaultPingFailures is the default number of consecutive pings
+// that must fail before the session manager is considered lost.
+var DefaultPingFailures = 3
+
 // DefaultTimeout is the default timeout for waiting for
 // a reply from Non Session Manager.
 var DefaultTimeout = 5 * time.Second
@@ -68,12 +78,80 @@ type ClientConfig struct {
 	// Timeout is an amount of time we should wait for a response from the nsm server.
 	Timeout time.Duration
 
-	Session              Session
+	// Session is the original interface for handling session manager messages.
+	// It is used as the Handler if Handler is nil.
+	Session Session
+
+	// Handler handles the messages from the session manager.
+	// If Capabilities is nil they are inferred from the optional
+	// interfaces the Handler implements, see InferCapabilities.
+	Handler SessionHandler
+
 	ListenAddr           string
 	DialNetwork          string
 	NsmURL               string
 	WaitForAnnounceReply bool
 
+	// Standalone enables standalone mode when there is no NSM_URL.
+	// In standalone mode the client does not talk to a session manager.
+	// Instead the Session is opened with ProjectPath when the client
+	// is created and saved whenever Client.Save is called.
+	Standalone  bool
+	ProjectPath string
+
+	// ProgressInterval is the minimum amount of time between two progress
+	// messages sent by the Progress passed to ProgressOpener and ProgressSaver.
+	ProgressInterval time.Duration
+
+	// PingInterval enables checking if the session manager is alive.
+	// The client pings the session manager at this interval, and if it
+	// stops replying the client waits for it to come back and announces
+	// itself again (see ServerWatcher).
+	// Zero disables the liveness check.
+	PingInterval time.Duration
+
+	// PingFailures is the number of consecutive pings that must fail
+	// before the session manager is considered lost.
+	// Defaults to DefaultPingFailures.
+	PingFailures int
+
+	// AbortOnServerLost makes the client cancel the context of ongoing
+	// open and save operations when the session manager is lost
+	// (see ContextOpener and ContextSaver).
+	AbortOnServerLost bool
+
+	// SaveOnSignal makes HandleSignals save a standalone client
+	// when the process receives SIGUSR1.
+	SaveOnSignal bool
+
+	// AllowedSenders are the addresses (host:port), other than the session
+	// manager's, that may send /nsm messages to the client.
+	// Messages from any other address are dropped.
+	// By default the client's connection only receives messages from
+	// the session manager. If AllowedSenders is not empty the client
+	// receives messages from any address and drops the ones
+	// that are not allowed.
+	AllowedSenders []string
+
+	// RestrictMethods makes the client drop messages for the Handler's
+	// Methods that were not sent by the session manager or one of the
+	// AllowedSenders. Otherwise the Methods accept messages from any
+	// address the connection receives them from.
+	RestrictMethods bool
+
+	// RejectedMessage is an optional func that is called with every
+	// message that is dropped because of its sender.
+	RejectedMessage func(osc.Message)
+
+	// FinalStatus is an optional status message that is sent to the
+	// session manager when the client is closed.
+	// It is only sent if the client has declared CapClientMessage.
+	FinalStatus *ClientStatus
+
+	// UnmatchedReply is an optional func that is called with every /reply
+	// or /error message that does not belong to a pending request.
+	UnmatchedReply func(osc.Message)
+
 	failSend int // Trigger a failed Send for a particular call. The first send is 1.
 }
 
@@ -82,22 +160,64 @@ type Client struct {
 	ClientConfig
 	osc.Conn
 
-	ReplyChan chan osc.Message
-
+	dispatch   osc.Dispatcher
 	group      *errgroup.Group
+	parent     context.Context
 	ctx        context.Context
 	closedChan chan struct{}
+	closeOnce  sync.Once
+	closeErr   error
+	inflight   sync.WaitGroup
+	handlers   chan struct{}     // Limits the number of concurrent handlers.
+	operations chan func() error // Open, save and session_is_loaded in order.
+
+	opMu     sync.Mutex
+	opCtx    context.Context
+	opCancel context.CancelFunc
+
+	standalone bool
+
+	serverAddr *net.UDPAddr
+	allowed    []*net.UDPAddr
+	listening  bool // The connection is not connected to serverAddr.
+
+	signalMu sync.Mutex
+	signal   os.Signal
 
+	stateMu   sync.Mutex
+	state     State
+	stateSubs []chan State
+	session   *SessionInfo
+
+	serverMu sync.RWMutex
+	server   ServerInfo
+
+	pendingMu sync.Mutex
+	pending   map[string][]*pendingRequest
+
+	sendMu   sync.Mutex
 	currSend int
 }
 
 // NewClient creates a new nsm-enabled application.
-// If config.Session is nil then ErrNilSession will be returned.
-// If NSM_URL is not defined in the environment then ErrNoNsmURL will be returned.
+// If config.Session and config.Handler are nil then ErrNilSession will be returned.
+// If NSM_URL is not defined in the environment then ErrNoNsmURL will be returned,
+// unless config.Standalone is true.
+// If config.WaitForAnnounceReply is true and the server rejects the
+// client's announce message then the cause of the returned error is an
+// Error, see ErrorCode. Otherwise NewClient does not wait for the reply,
+// and a rejection stops the client: Wait returns a *StopError whose
+// cause is the Error.
+// If the Handler's methods use reserved or conflicting addresses
+// then the cause of the returned error is ErrReservedAddress
+// or ErrAddressConflict.
 // TODO: validate config?
 func NewClient(ctx context.Context, config ClientConfig) (*Client, error) {
-	if config.Session == nil {
-		return nil, ErrNilSession
+	if config.Handler == nil {
+		if config.Session == nil {
+			return nil, ErrNilSession
+		}
+		config.Handler = config.Session
 	}
 	if config.Timeout == time.Duration(0) {
 		config.Timeout = DefaultTimeout
@@ -107,12 +227,22 @@ func NewClient(ctx context.Context, config ClientConfig) (*Client, error) {
 	// Create the client.
 	c := &Client{
 		ClientConfig: config,
-		ReplyChan:    make(chan osc.Message),
 		closedChan:   make(chan struct{}),
+		handlers:     make(chan struct{}, maxHandlers),
+		operations:   make(chan func() error, maxHandlers),
+		pending:      map[string][]*pendingRequest{},
 		group:        g,
+		parent:       ctx,
 		ctx:          gctx,
 	}
 	c.Defaults()
+	c.opCtx, c.opCancel = context.WithCancel(gctx)
+
+	d, err := c.dispatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid handler")
+	}
+	c.dispatch = d
 
 	if err := c.Initialize(); err != nil {
 		return nil, errors.Wrap(err, "initialize client")
@@ -122,8 +252,14 @@ func NewClient(ctx context.Context, config ClientConfig) (*Client, error) {
 
 // Initialize initializes the client.
 func (c *Client) Initialize() error {
+	if err := c.resolveSenders(); err != nil {
+		return err
+	}
 	// Get connection.
 	if err := c.DialUDP(c.ListenAddr); err != nil {
+		if err == ErrNoNsmURL && c.Standalone {
+			return c.initializeStandalone()
+		}
 		return errors.Wrap(err, "dial udp")
 	}
 
@@ -146,6 +282,15 @@ func (c *Client) Defaults() {
 	if c.DialNetwork == "" {
 		c.DialNetwork = "udp"
 	}
+	if c.ProgressInterval == time.Duration(0) {
+		c.ProgressInterval = DefaultProgressInterval
+	}
+	if c.PingFailures == 0 {
+		c.PingFailures = DefaultPingFailures
+	}
+	if c.Capabilities == nil && c.Session == nil {
+		c.Capabilities = InferCapabilities(c.Handler)
+	}
 }
 
 // DialUDP initializes the connection to non session manager.
@@ -163,12 +308,13 @@ func (c *Client) DialUDP(localAddr string) error {
 		nsmURL = c.NsmURL
 	}
 
-	// Why?
-	nsmURL = strings.TrimPrefix(nsmURL, "osc.udp://")
-	nsmURL = strings.TrimSuffix(nsmURL, "/")
+	u, err := ParseURL(nsmURL)
+	if err != nil {
+		return errors.Wrap(err, "parse "+NsmURL)
+	}
 
 	// Get OSC connection.
-	raddr, err := net.ResolveUDPAddr(c.DialNetwork, nsmURL)
+	raddr, err := net.ResolveUDPAddr(c.DialNetwork, u.HostPort())
 	if err != nil {
 		return errors.Wrap(err, "resolve udp remote address")
 	}
@@ -176,6 +322,18 @@ func (c *Client) DialUDP(localAddr string) error {
 	if err != nil {
 		return errors.Wrap(err, "resolve udp listening address")
 	}
+	c.serverAddr = raddr
+
+	// Other senders can only reach a connection that is not connected to the server.
+	if len(c.allowed) > 0 {
+		conn, err := osc.ListenUDPContext(c.ctx, c.DialNetwork, laddr)
+		if err != nil {
+			return errors.Wrap(err, "listen udp")
+		}
+		c.Conn = conn
+		c.listening = true
+		return nil
+	}
 	conn, _ := osc.DialUDPContext(c.ctx, c.DialNetwork, laddr, raddr) // Never fails
 	c.Conn = conn
 
@@ -185,7 +343,12 @@ func (c *Client) DialUDP(localAddr string) error {
 // StartOSC starts the osc server.
 func (c *Client) StartOSC() {
 	c.Go(c.serveOSC)
+	c.Go(c.runOperations)
 	c.Go(c.handleClientInfo)
+
+	if c.PingInterval > 0 && !c.standalone {
+		c.Go(c.watchServer)
+	}
 }
 
 // Go runs a goroutine as part of an errgroup.Group
@@ -194,63 +357,229 @@ func (c *Client) Go(f func() error) {
 }
 
 // Wait waits for all the goroutines in an errgroup.Group to finish
+// It returns nil if the client was closed with Close,
+// otherwise the error is a *StopError that tells why the client stopped.
 func (c *Client) Wait() error {
-	return c.group.Wait()
+	return c.stopError(c.group.Wait())
 }
 
 // Send sends an osc message.
+// It is safe to call Send from any goroutine.
+// In standalone mode messages are silently dropped.
 func (c *Client) Send(msg osc.Message) error {
-	if c.failSend == 0 {
-		return c.Conn.Send(msg)
+	if c.standalone {
+		return nil
 	}
-	c.currSend++
-	if c.currSend == c.failSend {
-		return errors.New("fail send")
+	if c.failSend != 0 {
+		c.sendMu.Lock()
+		c.currSend++
+		fail := c.currSend == c.failSend
+		c.sendMu.Unlock()
+
+		if fail {
+			return errors.New("fail send")
+		}
+	}
+	if c.listening {
+		return c.Conn.SendTo(c.serverAddr, msg)
 	}
 	return c.Conn.Send(msg)
 }
 
+// ServerInfo returns the information the server sent
+// in reply to the client's announce message.
+func (c *Client) ServerInfo() ServerInfo {
+	c.serverMu.RLock()
+	defer c.serverMu.RUnlock()
+
+	return c.server
+}
+
 // Close closes the nsm client.
+// The context of any ongoing open or save operation is cancelled,
+// and Close waits for at most Timeout for the operations to finish.
+// If they do not finish in time the Handler is not cleaned up (see Cleaner)
+// and the cause of the returned error is ErrTimeout.
+// If FinalStatus is set it is sent before the connection is closed.
+// It is safe to call Close more than once, and from any goroutine.
 func (c *Client) Close() error {
-	close(c.ReplyChan)
-	close(c.closedChan)
-	return c.Conn.Close()
+	c.closeOnce.Do(func() {
+		c.closeErr = c.close()
+	})
+	return c.closeErr
 }
 
 // serveOSC listens for incoming messages from Non Session Manager.
+// Messages are dispatched by a single worker so that replies are
+// delivered in the order they arrive, e.g. the replies to a list message.
+// Open, save and session_is_loaded are handled in order by runOperations,
+// and every other message by one of at most maxHandlers goroutines (see async),
+// so handlers that wait for a reply from the server do not block it.
 func (c *Client) serveOSC() error {
-	// Arbitrary number of worker routines.
-	return c.Serve(8, c.dispatcher())
+	// serveOSC is the only sender on operations.
+	defer close(c.operations)
+
+	for {
+		err := c.Serve(1, c.dispatch)
+
+		// Reading from the connection fails while the session manager is gone.
+		// Keep serving if we are waiting for it to come back.
+		if c.PingInterval > 0 && isConnRefused(err) {
+			continue
+		}
+		return err
+	}
 }
 
 // dispatcher returns the osc Dispatcher for the nsm client.
-func (c *Client) dispatcher() osc.Dispatcher {
+// Only the session manager and the AllowedSenders can send
+// messages to the built-in methods.
+// An error is returned if the Handler's methods, broadcasts or
+// observers have invalid addresses (see validateAddresses).
+func (c *Client) dispatcher() (osc.Dispatcher, error) {
 	d := osc.Dispatcher{
 		AddressReply: osc.Method(func(msg osc.Message) error {
-			c.ReplyChan <- msg
-			return nil
+			return c.dispatchReply(msg)
+		}),
+		AddressError: osc.Method(func(msg osc.Message) error {
+			return c.dispatchReply(msg)
 		}),
 		AddressClientOpen: osc.Method(func(msg osc.Message) error {
 			return c.handleOpen(msg)
 		}),
 		AddressClientSave: osc.Method(func(msg osc.Message) error {
-			response, nsmerr := c.Session.Save()
-			return c.handle(AddressClientSave, response, nsmerr)
+			return c.handleSave(msg)
 		}),
-		AddressClientSessionIsLoaded: osc.Method(func(msg osc.Message) error {
-			return c.Session.IsLoaded()
+		AddressClientSessionIsLoaded: c.queued(func(msg osc.Message) error {
+			if l, ok := c.Handler.(Loader); ok {
+				return l.IsLoaded()
+			}
+			return nil
 		}),
 		AddressClientShowOptionalGUI: osc.Method(func(msg osc.Message) error {
-			return c.Session.ShowGUI(true)
+			return c.showGUI(true)
 		}),
 		AddressClientHideOptionalGUI: osc.Method(func(msg osc.Message) error {
-			return c.Session.ShowGUI(false)
+			return c.showGUI(false)
 		}),
 	}
-	for address, handler := range c.Session.Methods() {
+	var methods, broadcasts, observers osc.Dispatcher
+
+	if mp, ok := c.Handler.(MethodProvider); ok {
+		methods = mp.Methods()
+	}
+	if br, ok := c.Handler.(BroadcastReceiver); ok {
+		broadcasts = br.Broadcasts()
+	}
+	if mo, ok := c.Handler.(MessageObserver); ok {
+		observers = mo.Observers()
+	}
+	if err := validateAddresses(d, methods, broadcasts, observers); err != nil {
+		return nil, err
+	}
+	for address, observer := range observers {
+		if isOperation(address) {
+			// Observe the operation once it is done.
+			observer = c.queued(observer)
+		}
+		d[address] = observe(d[address], observer)
+	}
+	// Broadcasts are relayed by the session manager.
+	for address, handler := range broadcasts {
+		d[address] = handler
+	}
+	// Senders are checked before a message is queued or
+	// a goroutine is started for it.
+	for address, handler := range d {
+		if address != AddressReply && address != AddressError && !isOperation(address) {
+			handler = c.async(handler)
+		}
+		d[address] = c.restrict(handler)
+	}
+	for address, handler := range methods {
+		handler = c.async(handler)
+		if c.RestrictMethods {
+			handler = c.restrict(handler)
+		}
 		d[address] = handler
 	}
-	return d
+	return d, nil
+}
+
+// maxHandlers is the number of messages that can be handled concurrently
+// (see async), and the number of operations that can wait to be run
+// (see enqueue).
+const maxHandlers = 8
+
+// isOperation returns true if the provided address is one of the
+// session lifecycle messages that are handled in order (see runOperations).
+func isOperation(address string) bool {
+	switch address {
+	case AddressClientOpen, AddressClientSave, AddressClientSessionIsLoaded:
+		return true
+	}
+	return false
+}
+
+// async returns a method that runs handler in a goroutine of the client.
+// At most maxHandlers handlers run at the same time, when they are all
+// busy the method waits for one of them to return.
+// An error returned by handler stops the client.
+func (c *Client) async(handler osc.Method) osc.Method {
+	return func(msg osc.Message) error {
+		select {
+		case c.handlers <- struct{}{}:
+		case <-c.closedChan:
+			return nil
+		case <-c.ctx.Done():
+			return nil
+		}
+		c.Go(func() error {
+			defer func() { <-c.handlers }()
+
+			return errors.Wrap(handler(msg), "dispatch message")
+		})
+		return nil
+	}
+}
+
+// queued returns a method that runs handler after the operations
+// that are waiting to be run (see enqueue).
+func (c *Client) queued(handler osc.Method) osc.Method {
+	return func(msg osc.Message) error {
+		c.enqueue(func() error {
+			return handler(msg)
+		})
+		return nil
+	}
+}
+
+// enqueue queues an operation to be run by runOperations.
+// It must only be called by a message handler.
+func (c *Client) enqueue(op func() error) {
+	select {
+	case c.operations <- op:
+	case <-c.ctx.Done():
+	}
+}
+
+// runOperations runs the queued operations in the order they were queued.
+// An error returned by an operation stops the client.
+func (c *Client) runOperations() error {
+	for op := range c.operations {
+		if err := op(); err != nil {
+			return errors.Wrap(err, "dispatch message")
+		}
+	}
+	return nil
+}
+
+// showGUI shows or hides the Handler's GUI.
+func (c *Client) showGUI(show bool) error {
+	if g, ok := c.Handler.(GUIController); ok {
+		return g.ShowGUI(show)
+	}
+	return nil
 }
 
 // handle handles the return values from a Session's method.
@@ -1,15 +1,23 @@
 package nsm
 
 import (
+	"context"
 	"os"
-	"time"
 
 	"github.com/pkg/errors"
 	"github.com/scgolang/osc"
 )
 
 // Announce announces a new nsm application.
+// If the server rejects the client (e.g. because it implements an incompatible
+// version of the API) the returned error is an Error with the code the server sent.
 func (c *Client) Announce() error {
+	return c.announce(c.WaitForAnnounceReply)
+}
+
+// announce sends the announce message.
+// If wait is false the reply is handled in the background.
+func (c *Client) announce(wait bool) error {
 	// Send the announce message.
 	msg := osc.Message{
 		Address: AddressServerAnnounce,
@@ -26,19 +34,40 @@ func (c *Client) Announce() error {
 	if c.Name != "" {
 		msg.Arguments[0] = osc.String(c.Name)
 	}
+	req := c.register(AddressServerAnnounce)
 	if err := c.Send(msg); err != nil {
+		c.unregister(req)
 		return errors.Wrap(err, "send announce message")
 	}
-	if !c.WaitForAnnounceReply {
+	if !wait {
+		// Handle the reply in the background so that the
+		// server info is recorded whenever it arrives.
+		c.Go(func() error {
+			defer c.unregister(req)
+
+			if err := c.waitAnnounce(req); err != nil && err != ErrTimeout && err != ErrClosed && c.ctx.Err() == nil {
+				return err
+			}
+			return nil
+		})
 		return nil
 	}
+	defer c.unregister(req)
 
-	select {
-	case <-time.After(c.Timeout):
-		return errors.New("timeout")
-	case reply := <-c.ReplyChan:
-		return errors.Wrap(c.handleAnnounce(reply), "handle announce reply")
-	}
+	return c.waitAnnounce(req)
+}
+
+// waitAnnounce waits for the reply to the announce message.
+func (c *Client) waitAnnounce(req *pendingRequest) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.Timeout)
+	defer cancel()
+
+	return c.wait(ctx, req, func(reply osc.Message) (bool, error) {
+		if reply.Address == AddressError {
+			return true, readError(reply)
+		}
+		return true, errors.Wrap(c.handleAnnounce(reply), "handle announce reply")
+	})
 }
 
 // handleAnnounce handles a reply to the announce message.
@@ -66,9 +95,19 @@ func (c *Client) handleAnnounce(msg osc.Message) error {
 	if err != nil {
 		return errors.Wrap(err, "read session manager capabilities")
 	}
-	return c.Session.Announce(ServerInfo{
+	info := ServerInfo{
 		Message:      serverMsg,
 		ServerName:   smName,
 		Capabilities: ParseCapabilities(capsRaw),
-	})
+	}
+	c.serverMu.Lock()
+	c.server = info
+	c.serverMu.Unlock()
+
+	c.announced()
+
+	if a, ok := c.Handler.(Announcer); ok {
+		return a.Announce(info)
+	}
+	return nil
 }
@@ -3,7 +3,9 @@ package nsm
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/scgolang/osc"
 )
 
@@ -47,10 +49,27 @@ func TestClientAnnounceReplyWrongAddress(t *testing.T) {
 	})
 	defer func() { _ = nsmd.Close() }() // Best effort.
 
-	if _, err := NewClient(context.Background(), testConfig()); err == nil {
-		t.Fatal("expected error, got nil")
-	} else {
-		if expected, got := `initialize client: announce app: handle announce reply: expected /nsm/server/announce, got /foo/bar`, err.Error(); expected != got {
+	var (
+		config    = testConfig()
+		unmatched = make(chan osc.Message, 1)
+	)
+	config.Timeout = 200 * time.Millisecond
+	config.UnmatchedReply = func(msg osc.Message) {
+		unmatched <- msg
+	}
+	// A reply to another address does not belong to the announce.
+	if _, err := NewClient(context.Background(), config); errors.Cause(err) != ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %+v", err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	case msg := <-unmatched:
+		addr, err := msg.Arguments[0].ReadString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := "/foo/bar", addr; expected != got {
 			t.Fatalf("expected %s, got %s", expected, got)
 		}
 	}
@@ -178,3 +197,88 @@ func TestClientAnnounceSendError(t *testing.T) {
 		t.Fatalf("expected %s, got %s", expected, got)
 	}
 }
+
+func TestClientAnnounceErrorReply(t *testing.T) {
+	for i, testcase := range []struct {
+		code    Code
+		message string
+	}{
+		{code: ErrIncompatibleAPI, message: "incompatible API version"},
+		{code: ErrBlacklisted, message: "go away"},
+	} {
+		// mockNsmd sets an environment variable to point the client to it's listening address
+		nsmd := newMockNsmd(t, mockNsmdConfig{
+			listenAddr: "127.0.0.1:0",
+			announceReply: osc.Message{
+				Address: AddressError,
+				Arguments: osc.Arguments{
+					osc.String(AddressServerAnnounce),
+					osc.Int(int32(testcase.code)),
+					osc.String(testcase.message),
+				},
+			},
+		})
+		_, err := NewClient(context.Background(), testConfig())
+		_ = nsmd.Close() // Best effort.
+
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		code, ok := ErrorCode(err)
+		if !ok {
+			t.Fatalf("(testcase %d) expected an Error, got %+v", i, err)
+		}
+		if expected, got := testcase.code, code; expected != got {
+			t.Fatalf("(testcase %d) expected %d, got %d", i, expected, got)
+		}
+		if expected, got := `initialize client: announce app: `+testcase.message, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestClientAnnounceErrorReplyNoWait(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{
+		listenAddr: "127.0.0.1:0",
+		announceReply: osc.Message{
+			Address: AddressError,
+			Arguments: osc.Arguments{
+				osc.String(AddressServerAnnounce),
+				osc.Int(int32(ErrBlacklisted)),
+				osc.String("go away"),
+			},
+		},
+	})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	config := testConfig()
+	config.WaitForAnnounceReply = false
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- c.Wait()
+	}()
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	case err := <-errChan:
+		stopErr, ok := err.(*StopError)
+		if !ok {
+			t.Fatalf("expected *StopError, got %+v", err)
+		}
+		if expected, got := StopFailed, stopErr.Reason; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+		code, ok := ErrorCode(err)
+		if !ok {
+			t.Fatalf("expected an Error, got %+v", err)
+		}
+		if expected, got := ErrBlacklisted, code; expected != got {
+			t.Fatalf("expected %d, got %d", expected, got)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package nsm
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// ErrServerBroadcast is returned by Broadcast if the server
+// did not advertise CapServerBroadcast.
+var ErrServerBroadcast = errors.New("server does not support " + string(CapServerBroadcast))
+
+// Broadcast asks the session manager to relay a message to all the other
+// clients in the session. Clients receive the message at msg.Address,
+// see BroadcastReceiver.
+// Addresses beginning with /nsm (see AddressPrefix) can not be broadcast.
+func (c *Client) Broadcast(msg osc.Message) error {
+	if !c.ServerInfo().Capabilities.Contains(CapServerBroadcast) {
+		return ErrServerBroadcast
+	}
+	if strings.HasPrefix(msg.Address, AddressPrefix) {
+		return errors.New("can not broadcast " + msg.Address)
+	}
+	return errors.Wrap(c.Send(osc.Message{
+		Address:   AddressServerBroadcast,
+		Arguments: append(osc.Arguments{osc.String(msg.Address)}, msg.Arguments...),
+	}), "send broadcast message")
+}
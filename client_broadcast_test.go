@@ -0,0 +1,115 @@
+package nsm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+func TestClientBroadcast(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	c := newClient(t, testConfig())
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if err := c.Broadcast(osc.Message{
+		Address:   "/tempo",
+		Arguments: osc.Arguments{osc.Float(120)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	case msg := <-nsmd.broadcastChan:
+		if expected, got := 2, len(msg.Arguments); expected != got {
+			t.Fatalf("expected %d arguments, got %d", expected, got)
+		}
+		addr, err := msg.Arguments[0].ReadString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := "/tempo", addr; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+		tempo, err := msg.Arguments[1].ReadFloat32()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := float32(120), tempo; expected != got {
+			t.Fatalf("expected %f, got %f", expected, got)
+		}
+	}
+	for _, address := range []string{AddressClientSave, AddressPrefix, AddressPrefix + "foo"} {
+		if err := c.Broadcast(osc.Message{Address: address}); err == nil {
+			t.Fatalf("expected error broadcasting %s, got nil", address)
+		}
+	}
+}
+
+func TestClientBroadcastNotSupported(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{
+		listenAddr: "127.0.0.1:0",
+		announceReply: osc.Message{
+			Address: AddressReply,
+			Arguments: osc.Arguments{
+				osc.String(AddressServerAnnounce),
+				osc.String("session started"),
+				osc.String("mock_nsmd"),
+				osc.String(Capabilities{CapServerControl}.String()),
+			},
+		},
+	})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	c := newClient(t, testConfig())
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if err := c.Broadcast(osc.Message{Address: "/tempo"}); err != ErrServerBroadcast {
+		t.Fatalf("expected ErrServerBroadcast, got %+v", err)
+	}
+}
+
+func TestClientReceiveBroadcast(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config    = testConfig()
+		tempoChan = make(chan float32)
+	)
+	config.Session = &mockSession{
+		broadcasts: osc.Dispatcher{
+			"/tempo": func(msg osc.Message) error {
+				tempo, err := msg.Arguments[0].ReadFloat32()
+				if err != nil {
+					return err
+				}
+				tempoChan <- tempo
+				return nil
+			},
+		},
+	}
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if err := nsmd.SendTo(c.LocalAddr(), osc.Message{
+		Address:   "/tempo",
+		Arguments: osc.Arguments{osc.Float(98)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	case tempo := <-tempoChan:
+		if expected, got := float32(98), tempo; expected != got {
+			t.Fatalf("expected %f, got %f", expected, got)
+		}
+	}
+}
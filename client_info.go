@@ -8,25 +8,45 @@ import (
 // handleClientInfo runs a goroutine that handles the client to server informational messages.
 // This is a persistent goroutine that closes the client's connection when it exits.
 func (c *Client) handleClientInfo() error {
+	// The channels are only requested once,
+	// so a Handler may return a new channel from every call.
+	var (
+		dirty      chan bool
+		guiShowing chan bool
+		progress   chan float32
+		status     chan ClientStatus
+	)
+	if n, ok := c.Handler.(DirtyNotifier); ok {
+		dirty = n.Dirty()
+	}
+	if n, ok := c.Handler.(GUINotifier); ok {
+		guiShowing = n.GUIShowing()
+	}
+	if n, ok := c.Handler.(ProgressNotifier); ok {
+		progress = n.Progress()
+	}
+	if n, ok := c.Handler.(StatusNotifier); ok {
+		status = n.ClientStatus()
+	}
 	for {
 		select {
 		case <-c.closedChan:
 			return nil
 		case <-c.ctx.Done():
 			return c.ctx.Err()
-		case isDirty := <-c.Session.Dirty():
+		case isDirty := <-dirty:
 			if err := c.sendDirty(isDirty); err != nil {
 				return errors.Wrap(err, "send dirty message")
 			}
-		case isGUIShowing := <-c.Session.GUIShowing():
+		case isGUIShowing := <-guiShowing:
 			if err := c.sendGUIShowing(isGUIShowing); err != nil {
 				return errors.Wrap(err, "send gui-showing message")
 			}
-		case x := <-c.Session.Progress():
+		case x := <-progress:
 			if err := c.sendProgress(x); err != nil {
 				return errors.Wrap(err, "send progress message")
 			}
-		case clientStatus := <-c.Session.ClientStatus():
+		case clientStatus := <-status:
 			if err := c.sendClientStatus(clientStatus); err != nil {
 				return errors.Wrap(err, "send client status message")
 			}
@@ -34,6 +54,60 @@ func (c *Client) handleClientInfo() error {
 	}
 }
 
+// SetDirty tells Non Session Manager whether the client has unsaved changes.
+// The client must have declared CapClientDirty.
+// It is safe to call SetDirty from any goroutine.
+func (c *Client) SetDirty(isDirty bool) error {
+	if err := c.requireCapability(CapClientDirty); err != nil {
+		return err
+	}
+	return errors.Wrap(c.sendDirty(isDirty), "send dirty message")
+}
+
+// SetGUIShowing tells Non Session Manager whether the client's GUI is showing.
+// The client must have declared CapGUI.
+// It is safe to call SetGUIShowing from any goroutine.
+func (c *Client) SetGUIShowing(isGUIShowing bool) error {
+	if err := c.requireCapability(CapGUI); err != nil {
+		return err
+	}
+	return errors.Wrap(c.sendGUIShowing(isGUIShowing), "send gui-showing message")
+}
+
+// ReportProgress tells Non Session Manager how far an open or save
+// operation has progressed. x is clamped to [0, 1].
+// The client must have declared CapClientProgress.
+// It is safe to call ReportProgress from any goroutine.
+func (c *Client) ReportProgress(x float32) error {
+	if err := c.requireCapability(CapClientProgress); err != nil {
+		return err
+	}
+	if x < 0 {
+		x = 0
+	} else if x > 1 {
+		x = 1
+	}
+	return errors.Wrap(c.sendProgress(x), "send progress message")
+}
+
+// SendStatus sends a status message to Non Session Manager.
+// The client must have declared CapClientMessage.
+// It is safe to call SendStatus from any goroutine.
+func (c *Client) SendStatus(status ClientStatus) error {
+	if err := c.requireCapability(CapClientMessage); err != nil {
+		return err
+	}
+	return errors.Wrap(c.sendClientStatus(status), "send client status message")
+}
+
+// requireCapability returns an error if the client has not declared a capability.
+func (c *Client) requireCapability(capability Capability) error {
+	if !c.Capabilities.Contains(capability) {
+		return errors.Errorf("client does not have the %s capability", capability)
+	}
+	return nil
+}
+
 // sendDirty sends an OSC message telling Non Session Manager
 // if the client has unsaved changes.
 func (c *Client) sendDirty(isDirty bool) error {
@@ -291,3 +291,99 @@ func TestClientStatusFailSend(t *testing.T) {
 		}
 	}
 }
+
+func TestClientPushNotifications(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	config := testConfig()
+	config.Capabilities = Capabilities{CapClientDirty, CapClientProgress, CapClientMessage, CapGUI}
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	errChan := make(chan error, 4)
+	go func() { errChan <- c.SetDirty(true) }()
+	go func() { errChan <- c.SetGUIShowing(true) }()
+	go func() { errChan <- c.ReportProgress(1.5) }()
+	go func() { errChan <- c.SendStatus(ClientStatus{Priority: PriorityHigh, Message: "hello"}) }()
+
+	for i := 0; i < 4; i++ {
+		select {
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout")
+		case isDirty := <-nsmd.dirtyChan:
+			if !isDirty {
+				t.Fatal("expected dirty")
+			}
+		case isShowing := <-nsmd.guiShowingChan:
+			if !isShowing {
+				t.Fatal("expected gui showing")
+			}
+		case x := <-nsmd.progressChan:
+			if expected, got := float32(1), x; expected != got {
+				t.Fatalf("expected %f, got %f", expected, got)
+			}
+		case status := <-nsmd.statusChan:
+			if expected, got := "hello", status.Message; expected != got {
+				t.Fatalf("expected %s, got %s", expected, got)
+			}
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-errChan; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestClientPushNotificationsCapabilities(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	config := testConfig()
+	config.Capabilities = Capabilities{}
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	for i, testcase := range []struct {
+		err      error
+		expected string
+	}{
+		{err: c.SetDirty(true), expected: `client does not have the dirty capability`},
+		{err: c.SetGUIShowing(true), expected: `client does not have the optional-gui capability`},
+		{err: c.ReportProgress(0.5), expected: `client does not have the progress capability`},
+		{err: c.SendStatus(ClientStatus{}), expected: `client does not have the message capability`},
+	} {
+		if testcase.err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.expected, testcase.err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestClientPushNotificationFailSend(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	config := testConfig()
+	config.Capabilities = Capabilities{CapClientDirty}
+	config.failSend = 2
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	err := c.SetDirty(true)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if expected, got := `send dirty message: fail send`, err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
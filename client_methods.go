@@ -0,0 +1,67 @@
+package nsm
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// AddressPrefix is the prefix of the addresses that are reserved
+// for the communication between the client and the session manager.
+const AddressPrefix = "/nsm"
+
+// Dispatcher errors.
+var (
+	ErrReservedAddress = errors.New("addresses beginning with " + AddressPrefix + " are reserved")
+	ErrAddressConflict = errors.New("address conflicts with another method")
+	ErrNotBuiltin      = errors.New("address is not handled by the client")
+)
+
+// validateAddresses checks the addresses the Handler adds to the client's dispatcher.
+// Methods and broadcasts must not use reserved addresses or the addresses of
+// the built-in methods, and must not conflict with each other.
+// Observers must use the address of a built-in method.
+func validateAddresses(builtins, methods, broadcasts, observers osc.Dispatcher) error {
+	for address := range methods {
+		if err := validateAddress(builtins, address); err != nil {
+			return errors.Wrap(err, "method "+address)
+		}
+		if _, ok := broadcasts[address]; ok {
+			return errors.Wrap(ErrAddressConflict, "method "+address)
+		}
+	}
+	for address := range broadcasts {
+		if err := validateAddress(builtins, address); err != nil {
+			return errors.Wrap(err, "broadcast "+address)
+		}
+	}
+	for address := range observers {
+		if _, ok := builtins[address]; !ok {
+			return errors.Wrap(ErrNotBuiltin, "observer "+address)
+		}
+	}
+	return nil
+}
+
+// validateAddress checks a user-provided address.
+func validateAddress(builtins osc.Dispatcher, address string) error {
+	if strings.HasPrefix(address, AddressPrefix) {
+		return ErrReservedAddress
+	}
+	if _, ok := builtins[address]; ok {
+		return ErrAddressConflict
+	}
+	return nil
+}
+
+// observe returns a method that calls observer after the built-in method.
+// The built-in method's error takes precedence.
+func observe(builtin, observer osc.Method) osc.Method {
+	return func(msg osc.Message) error {
+		if err := builtin(msg); err != nil {
+			return err
+		}
+		return errors.Wrap(observer(msg), "observe "+msg.Address)
+	}
+}
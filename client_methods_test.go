@@ -0,0 +1,134 @@
+package nsm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+func nopMethod(msg osc.Message) error { return nil }
+
+func TestValidateAddresses(t *testing.T) {
+	builtins := osc.Dispatcher{AddressClientOpen: nopMethod}
+
+	for i, testcase := range []struct {
+		methods, broadcasts, observers osc.Dispatcher
+		expected                       error
+		message                        string
+	}{
+		{
+			methods:    osc.Dispatcher{"/foo": nopMethod},
+			broadcasts: osc.Dispatcher{"/bar": nopMethod},
+			observers:  osc.Dispatcher{AddressClientOpen: nopMethod},
+		},
+		{
+			methods:  osc.Dispatcher{"/nsm/foo": nopMethod},
+			expected: ErrReservedAddress,
+			message:  "method /nsm/foo: addresses beginning with /nsm are reserved",
+		},
+		{
+			methods:  osc.Dispatcher{AddressClientOpen: nopMethod},
+			expected: ErrReservedAddress,
+			message:  "method /nsm/client/open: addresses beginning with /nsm are reserved",
+		},
+		{
+			methods:    osc.Dispatcher{"/foo": nopMethod},
+			broadcasts: osc.Dispatcher{"/foo": nopMethod},
+			expected:   ErrAddressConflict,
+			message:    "method /foo: address conflicts with another method",
+		},
+		{
+			broadcasts: osc.Dispatcher{"/nsmfoo": nopMethod},
+			expected:   ErrReservedAddress,
+			message:    "broadcast /nsmfoo: addresses beginning with /nsm are reserved",
+		},
+		{
+			observers: osc.Dispatcher{"/foo": nopMethod},
+			expected:  ErrNotBuiltin,
+			message:   "observer /foo: address is not handled by the client",
+		},
+	} {
+		err := validateAddresses(builtins, testcase.methods, testcase.broadcasts, testcase.observers)
+		if expected, got := testcase.expected, errors.Cause(err); expected != got {
+			t.Fatalf("(testcase %d) expected %v, got %v", i, expected, got)
+		}
+		if err == nil {
+			continue
+		}
+		if expected, got := testcase.message, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestValidateAddressesBuiltin(t *testing.T) {
+	builtins := osc.Dispatcher{AddressReply: nopMethod}
+	err := validateAddresses(builtins, osc.Dispatcher{AddressReply: nopMethod}, nil, nil)
+	if expected, got := ErrAddressConflict, errors.Cause(err); expected != got {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestNewClientReservedMethod(t *testing.T) {
+	config := testConfig()
+	config.Session = &mockSession{
+		methods: osc.Dispatcher{AddressClientSave: nopMethod},
+	}
+	_, err := NewClient(context.Background(), config)
+	if expected, got := ErrReservedAddress, errors.Cause(err); expected != got {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if expected, got := "invalid handler: method /nsm/client/save: addresses beginning with /nsm are reserved", err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+// observingHandler observes open messages.
+type observingHandler struct {
+	minimalHandler
+
+	observed chan osc.Message
+}
+
+func (h *observingHandler) Observers() osc.Dispatcher {
+	return osc.Dispatcher{
+		AddressClientOpen: func(msg osc.Message) error {
+			h.observed <- msg
+			return nil
+		},
+	}
+}
+
+func TestClientObserver(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config  = testConfig()
+		handler = &observingHandler{observed: make(chan osc.Message, 1)}
+	)
+	config.Session = nil
+	config.Handler = handler
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	// The client still handles the message.
+	reply := nsmd.OpenSession(testOpenMessage)
+
+	message, err := reply.Arguments[1].ReadString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "opened", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	msg := <-handler.observed
+
+	if expected, got := AddressClientOpen, msg.Address; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
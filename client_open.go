@@ -22,14 +22,77 @@ func (c *Client) handleOpen(msg osc.Message) error {
 	if err != nil {
 		return errors.Wrap(err, "could not read client ID")
 	}
-	response, nsmerr := c.Session.Open(SessionInfo{
+	info := SessionInfo{
 		ProjectPath: projectPath,
 		DisplayName: displayName,
 		ClientID:    clientID,
 		LocalAddr:   c.LocalAddr(),
-	})
-	if err := c.handle(AddressClientOpen, response, nsmerr); err != nil {
-		return errors.Wrap(err, "could not respond to "+AddressClientOpen)
 	}
+	// Only one open or save runs at a time.
+	prev, nsmerr := c.begin(StateOpening)
+	if nsmerr != nil {
+		return errors.Wrap(c.handleError(AddressClientOpen, nsmerr), "could not respond to "+AddressClientOpen)
+	}
+	respond := func() error {
+		defer c.inflight.Done()
+
+		response, nsmerr := c.open(info)
+		c.end(prev, nsmerr)
+
+		if err := c.handle(AddressClientOpen, response, nsmerr); err != nil {
+			return errors.Wrap(err, "could not respond to "+AddressClientOpen)
+		}
+		return nil
+	}
+	// Run the operation after the ones that are queued,
+	// so the connection keeps being served while it runs.
+	c.enqueue(respond)
 	return nil
 }
+
+// open opens a session.
+// If a session is already open and the Handler is a Switcher then
+// it switches to the new session instead.
+func (c *Client) open(info SessionInfo) (string, Error) {
+	var (
+		response string
+		nsmerr   Error
+
+		prev, isOpen  = c.CurrentSession()
+		s, isSwitcher = c.Handler.(Switcher)
+	)
+	if isOpen && isSwitcher {
+		response, nsmerr = s.Switch(prev, info)
+	} else {
+		response, nsmerr = c.openHandler(info)
+	}
+	if nsmerr == nil {
+		c.stateMu.Lock()
+		c.session = &info
+		c.stateMu.Unlock()
+	}
+	return response, nsmerr
+}
+
+// openHandler calls the most specific open method the Handler implements.
+func (c *Client) openHandler(info SessionInfo) (string, Error) {
+	if o, ok := c.Handler.(ProgressOpener); ok {
+		return o.OpenProgress(c.operationContext(), info, c.newProgress())
+	}
+	if o, ok := c.Handler.(ContextOpener); ok {
+		return o.OpenContext(c.operationContext(), info)
+	}
+	return c.Handler.Open(info)
+}
+
+// CurrentSession returns the SessionInfo of the open session.
+// It returns false if no session has been opened.
+func (c *Client) CurrentSession() (SessionInfo, bool) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	if c.session == nil {
+		return SessionInfo{}, false
+	}
+	return *c.session, true
+}
@@ -260,8 +260,163 @@ func TestClientOpenSendReplyError(t *testing.T) {
 		if err == nil {
 			t.Fatal("expected an error, got nil")
 		}
-		if expected, got := `error serving udp: dispatch message: could not respond to /nsm/client/open: send error: fail send`, err.Error(); expected != got {
+		if expected, got := `dispatch message: could not respond to /nsm/client/open: send error: fail send`, err.Error(); expected != got {
 			t.Fatalf("expeccted %s, got %s", expected, got)
 		}
 	}
 }
+
+// switchHandler records the sessions it switches between.
+type switchHandler struct {
+	minimalHandler
+
+	prev, next SessionInfo
+}
+
+func (h *switchHandler) Switch(prev, next SessionInfo) (string, Error) {
+	h.prev, h.next = prev, next
+	return "switched", nil
+}
+
+// openMessage returns an open message for the provided project path.
+func openMessage(projectPath string) osc.Message {
+	return osc.Message{
+		Address: AddressClientOpen,
+		Arguments: osc.Arguments{
+			osc.String(projectPath),
+			osc.String("display_name"),
+			osc.String("client_id"),
+		},
+	}
+}
+
+func TestClientOpenNoSwitch(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	config := testConfig()
+	config.Session = nil
+	config.Capabilities = nil
+	config.Handler = &minimalHandler{}
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	_ = nsmd.OpenSession(openMessage("./first"))
+
+	nsmErr := nsmd.OpenSessionError(openMessage("./second"))
+	if expected, got := ErrGeneral, nsmErr.Code(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	info, ok := c.CurrentSession()
+	if !ok {
+		t.Fatal("expected a session to be open")
+	}
+	if expected, got := "./first", info.ProjectPath; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestClientOpenSwitch(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config  = testConfig()
+		handler = &switchHandler{}
+	)
+	config.Session = nil
+	config.Capabilities = nil
+	config.Handler = handler
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if !c.Capabilities.Contains(CapClientSwitch) {
+		t.Fatalf("expected inferred capabilities to contain %s", CapClientSwitch)
+	}
+	if _, ok := c.CurrentSession(); ok {
+		t.Fatal("expected no session to be open")
+	}
+	_ = nsmd.OpenSession(openMessage("./first"))
+
+	if expected, got := "./first", handler.info.ProjectPath; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	reply := nsmd.OpenSession(openMessage("./second"))
+
+	message, err := reply.Arguments[1].ReadString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "switched", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := "./first", handler.prev.ProjectPath; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := "./second", handler.next.ProjectPath; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	info, _ := c.CurrentSession()
+	if expected, got := "./second", info.ProjectPath; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+// slowOpener takes a while to open and records the order of its calls.
+type slowOpener struct {
+	minimalHandler
+
+	calls chan string
+}
+
+func (h *slowOpener) Open(info SessionInfo) (string, Error) {
+	time.Sleep(100 * time.Millisecond)
+	h.calls <- "open"
+	return h.minimalHandler.Open(info)
+}
+
+func (h *slowOpener) IsLoaded() error {
+	h.calls <- "loaded"
+	return nil
+}
+
+func TestClientOpenSessionIsLoadedOrder(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config  = testConfig()
+		handler = &slowOpener{calls: make(chan string, 2)}
+	)
+	config.Session = nil
+	config.Handler = handler
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if err := nsmd.SendTo(c.LocalAddr(), testOpenMessage); err != nil {
+		t.Fatal(err)
+	}
+	nsmd.SessionLoaded()
+
+	for _, expected := range []string{"open", "loaded"} {
+		select {
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %s", expected)
+		case got := <-handler.calls:
+			if expected != got {
+				t.Fatalf("expected %s, got %s", expected, got)
+			}
+		}
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for open reply")
+	case <-nsmd.openChan:
+	}
+}
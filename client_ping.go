@@ -0,0 +1,98 @@
+package nsm
+
+import (
+	"context"
+	stderrors "errors"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// Ping pings the session manager and waits for the reply.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.request(ctx, osc.Message{Address: AddressPing}, func(reply osc.Message) (bool, error) {
+		if reply.Address == AddressError {
+			return true, readError(reply)
+		}
+		return true, nil
+	})
+}
+
+// watchServer pings the session manager every PingInterval.
+// When PingFailures pings in a row fail the Session is notified
+// and the client keeps pinging until it replies again, then the
+// client announces itself with the same capabilities.
+func (c *Client) watchServer() error {
+	ticker := time.NewTicker(c.PingInterval)
+	defer ticker.Stop()
+
+	var (
+		alive    = true
+		failures = 0
+	)
+
+	for {
+		select {
+		case <-c.closedChan:
+			return nil
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		case <-ticker.C:
+		}
+		timeout := c.Timeout
+		if c.PingInterval < timeout {
+			timeout = c.PingInterval
+		}
+		ctx, cancel := context.WithTimeout(c.ctx, timeout)
+		err := c.Ping(ctx)
+		cancel()
+
+		if err != nil {
+			failures++
+		} else {
+			failures = 0
+		}
+		switch {
+		case failures >= c.PingFailures && alive:
+			alive = false
+			if c.AbortOnServerLost {
+				c.abortOperations()
+			}
+			if err := c.serverLost(); err != nil {
+				return errors.Wrap(err, "server lost")
+			}
+		case err == nil && !alive:
+			if err := c.announce(true); err != nil {
+				continue // Try again on the next tick.
+			}
+			alive = true
+			if err := c.serverRestored(); err != nil {
+				return errors.Wrap(err, "server restored")
+			}
+		}
+	}
+}
+
+// serverLost notifies the Session that the session manager went away.
+func (c *Client) serverLost() error {
+	if w, ok := c.Handler.(ServerWatcher); ok {
+		return w.ServerLost()
+	}
+	return nil
+}
+
+// serverRestored notifies the Session that the session manager came back.
+func (c *Client) serverRestored() error {
+	if w, ok := c.Handler.(ServerWatcher); ok {
+		return w.ServerRestored(c.ServerInfo())
+	}
+	return nil
+}
+
+// isConnRefused returns true if err was caused by the remote end
+// of a connection refusing packets.
+func isConnRefused(err error) bool {
+	return stderrors.Is(err, syscall.ECONNREFUSED) || stderrors.Is(errors.Cause(err), syscall.ECONNREFUSED)
+}
@@ -0,0 +1,194 @@
+package nsm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+func TestClientPing(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	c := newClient(t, testConfig())
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	addr := nsmd.LocalAddr().String()
+
+	var (
+		config  = testConfig()
+		session = &mockSession{
+			lostChan:     make(chan struct{}),
+			restoredChan: make(chan ServerInfo),
+		}
+	)
+	config.Session = session
+	config.PingInterval = 20 * time.Millisecond
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	// Stop the server.
+	if err := nsmd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for server to be lost")
+	case <-session.lostChan:
+	}
+
+	// Restart the server on the same address.
+	nsmd = newMockNsmd(t, mockNsmdConfig{listenAddr: addr})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for server to be restored")
+	case info := <-session.restoredChan:
+		if expected, got := "mock_nsmd", info.ServerName; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for client to announce itself again")
+	case <-nsmd.announceAcked:
+	}
+}
+
+// slowWatcher takes longer than the ping interval to save
+// and records whether the server was lost.
+type slowWatcher struct {
+	minimalHandler
+
+	duration time.Duration
+	lost     chan struct{}
+}
+
+func (h *slowWatcher) SaveContext(ctx context.Context) (string, Error) {
+	select {
+	case <-time.After(h.duration):
+		return "saved", nil
+	case <-ctx.Done():
+		return "", NewError(ErrGeneral, "save cancelled")
+	}
+}
+
+func (h *slowWatcher) ServerLost() error {
+	close(h.lost)
+	return nil
+}
+
+func (h *slowWatcher) ServerRestored(info ServerInfo) error {
+	return nil
+}
+
+func TestClientPingDuringSlowSave(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config  = testConfig()
+		handler = &slowWatcher{duration: 200 * time.Millisecond, lost: make(chan struct{})}
+	)
+	config.Session = nil
+	config.Handler = handler
+	config.PingInterval = 20 * time.Millisecond
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	_ = nsmd.OpenSession(testOpenMessage)
+
+	reply := nsmd.SaveSession(osc.Message{Address: AddressClientSave})
+
+	message, err := reply.Arguments[1].ReadString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "saved", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	select {
+	case <-handler.lost:
+		t.Fatal("expected server not to be lost while saving")
+	default:
+	}
+}
+
+func TestClientPingFailures(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0", dropPings: 2})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config  = testConfig()
+		handler = &slowWatcher{lost: make(chan struct{})}
+	)
+	config.Session = nil
+	config.Handler = handler
+	config.PingInterval = 20 * time.Millisecond
+	config.PingFailures = 3
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	select {
+	case <-handler.lost:
+		t.Fatal("expected server not to be lost after two missed pings")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestClientAbortOnServerLost(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+
+	var (
+		config  = testConfig()
+		handler = &ctxHandler{saving: make(chan struct{}), cancelled: make(chan error, 1)}
+	)
+	config.Session = nil
+	config.Handler = handler
+	config.PingInterval = 20 * time.Millisecond
+	config.AbortOnServerLost = true
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	_ = nsmd.OpenSession(testOpenMessage)
+
+	if err := nsmd.SendTo(c.LocalAddr(), osc.Message{Address: AddressClientSave}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for save to start")
+	case <-handler.saving:
+	}
+	// Stop the server.
+	if err := nsmd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for save to be cancelled")
+	case err := <-handler.cancelled:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %+v", err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package nsm
+
+import (
+	"sync"
+	"time"
+)
+
+// Progress reports the progress of an open or save operation
+// to the session manager.
+// Reports are throttled to one per ClientConfig.ProgressInterval,
+// except for the first report and reports of completion.
+type Progress struct {
+	c *Client
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// newProgress creates a Progress for a new operation.
+func (c *Client) newProgress() *Progress {
+	return &Progress{c: c}
+}
+
+// Report reports that the operation is x complete.
+// x is clamped to [0, 1], with 1 indicating completion.
+// Reports that are throttled return nil.
+// It is safe to call Report from any goroutine.
+func (p *Progress) Report(x float32) error {
+	p.mu.Lock()
+	now := time.Now()
+	if x < 1 && !p.last.IsZero() && now.Sub(p.last) < p.c.ProgressInterval {
+		p.mu.Unlock()
+		return nil
+	}
+	p.last = now
+	p.mu.Unlock()
+
+	return p.c.ReportProgress(x)
+}
@@ -0,0 +1,126 @@
+package nsm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+// progressHandler has a long-running save that waits to be released.
+type progressHandler struct {
+	minimalHandler
+
+	loaded  chan struct{}
+	shown   chan struct{}
+	release chan struct{}
+}
+
+func (h *progressHandler) IsLoaded() error {
+	close(h.loaded)
+	return nil
+}
+
+func (h *progressHandler) ShowGUI(show bool) error {
+	close(h.shown)
+	return nil
+}
+
+func (h *progressHandler) SaveProgress(ctx context.Context, progress *Progress) (string, Error) {
+	for _, x := range []float32{0.1, 0.2} { // 0.2 is throttled
+		if err := progress.Report(x); err != nil {
+			return "", NewError(ErrGeneral, err.Error())
+		}
+	}
+	<-h.release
+
+	if err := progress.Report(1.5); err != nil {
+		return "", NewError(ErrGeneral, err.Error())
+	}
+	return "saved", nil
+}
+
+func TestClientSaveProgress(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config  = testConfig()
+		handler = &progressHandler{
+			loaded:  make(chan struct{}),
+			shown:   make(chan struct{}),
+			release: make(chan struct{}),
+		}
+	)
+	config.Session = nil
+	config.Capabilities = nil
+	config.Handler = handler
+	config.ProgressInterval = time.Hour
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if !c.Capabilities.Contains(CapClientProgress) {
+		t.Fatalf("expected inferred capabilities to contain %s", CapClientProgress)
+	}
+	_ = nsmd.OpenSession(testOpenMessage)
+
+	if err := nsmd.SendTo(c.LocalAddr(), osc.Message{Address: AddressClientSave}); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []float32{0.1} {
+		select {
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for progress")
+		case got := <-nsmd.progressChan:
+			if expected != got {
+				t.Fatalf("expected %f, got %f", expected, got)
+			}
+		}
+	}
+
+	// The client keeps handling messages while the save is running.
+	nsmd.ShowOptionalGUI()
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for show_optional_gui")
+	case <-handler.shown:
+	}
+
+	// session_is_loaded is handled after the save.
+	nsmd.SessionLoaded()
+	select {
+	case <-time.After(50 * time.Millisecond):
+	case <-handler.loaded:
+		t.Fatal("expected session_is_loaded to wait for the save")
+	}
+	close(handler.release)
+
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for progress")
+	case got := <-nsmd.progressChan:
+		if expected := float32(1); expected != got {
+			t.Fatalf("expected %f, got %f", expected, got)
+		}
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for save reply")
+	case reply := <-nsmd.saveChan:
+		message, err := reply.Arguments[1].ReadString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := "saved", message; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for session_is_loaded")
+	case <-handler.loaded:
+	}
+}
@@ -0,0 +1,139 @@
+package nsm
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// pendingRequest is a request that is waiting for replies from the server.
+type pendingRequest struct {
+	address string
+	replies chan osc.Message
+	done    chan struct{}
+}
+
+// register adds a pending request for replies to the provided address.
+// Callers must unregister the request when they are done with it.
+func (c *Client) register(address string) *pendingRequest {
+	req := &pendingRequest{
+		address: address,
+		replies: make(chan osc.Message),
+		done:    make(chan struct{}),
+	}
+	c.pendingMu.Lock()
+	c.pending[address] = append(c.pending[address], req)
+	c.pendingMu.Unlock()
+
+	return req
+}
+
+// unregister removes a pending request.
+// Replies to the request that arrive afterwards are unmatched.
+func (c *Client) unregister(req *pendingRequest) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	reqs := c.pending[req.address]
+	for i, r := range reqs {
+		if r == req {
+			reqs = append(reqs[:i], reqs[i+1:]...)
+			break
+		}
+	}
+	if len(reqs) == 0 {
+		delete(c.pending, req.address)
+	} else {
+		c.pending[req.address] = reqs
+	}
+	close(req.done)
+}
+
+// dispatchReply passes a /reply or /error message to the oldest pending
+// request for the address it replies to.
+// The server must reply to the announce message before anything else,
+// so while the client is announcing a reply that can not be matched to
+// a pending request is treated as the reply to the announce message.
+// Other messages that do not belong to a pending request are passed to UnmatchedReply.
+func (c *Client) dispatchReply(msg osc.Message) error {
+	if req, ok := c.pendingRequest(msg); ok {
+		select {
+		case req.replies <- msg:
+			return nil
+		case <-req.done:
+		}
+	}
+	if c.UnmatchedReply != nil {
+		c.UnmatchedReply(msg)
+	}
+	return nil
+}
+
+// pendingRequest returns the pending request a reply belongs to.
+func (c *Client) pendingRequest(msg osc.Message) (*pendingRequest, bool) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	addr, err := readReplyAddress(msg)
+	if err != nil {
+		// Malformed replies go to a pending announce so it can fail
+		// instead of waiting for its timeout.
+		addr = AddressServerAnnounce
+	}
+	if reqs := c.pending[addr]; len(reqs) > 0 {
+		return reqs[0], true
+	}
+	return nil, false
+}
+
+// request sends a message and calls handle with every reply to it
+// until handle returns true or an error.
+// If ctx does not have a deadline then the client's Timeout is used.
+func (c *Client) request(ctx context.Context, msg osc.Message, handle func(osc.Message) (bool, error)) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	req := c.register(msg.Address)
+	defer c.unregister(req)
+
+	if err := c.Send(msg); err != nil {
+		return errors.Wrap(err, "send "+msg.Address)
+	}
+	return c.wait(ctx, req, handle)
+}
+
+// wait calls handle with every reply to a pending request
+// until handle returns true or an error.
+func (c *Client) wait(ctx context.Context, req *pendingRequest, handle func(osc.Message) (bool, error)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return ErrTimeout
+			}
+			return ctx.Err()
+		case <-c.closedChan:
+			return ErrClosed
+		case reply := <-req.replies:
+			done, err := handle(reply)
+			if err != nil || done {
+				return err
+			}
+		}
+	}
+}
+
+// readReplyAddress reads the address a /reply or /error message is replying to.
+func readReplyAddress(msg osc.Message) (string, error) {
+	if len(msg.Arguments) < 1 {
+		return "", errors.New(msg.Address + " must provide the address being replied to")
+	}
+	addr, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return "", errors.Wrap(err, "read reply address")
+	}
+	return addr, nil
+}
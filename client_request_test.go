@@ -0,0 +1,152 @@
+package nsm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+func TestClientConcurrentRequests(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{
+		listenAddr: "127.0.0.1:0",
+		sessions:   []string{"practice"},
+	})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	c := newClient(t, testConfig())
+	defer func() { _ = c.Close() }() // Best effort.
+
+	var (
+		ctx     = context.Background()
+		errChan = make(chan error)
+	)
+	go func() {
+		_, err := c.ServerSave(ctx)
+		errChan <- err
+	}()
+	go func() {
+		_, err := c.ListSessions(ctx)
+		errChan <- err
+	}()
+	go func() {
+		_, err := c.ServerOpen(ctx, "practice")
+		errChan <- err
+	}()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout")
+		case err := <-errChan:
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestClientRequestTimeout(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	c := newClient(t, testConfig())
+	defer func() { _ = c.Close() }() // Best effort.
+
+	// The mock does not reply to new.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.ServerNew(ctx, "foo"); err != ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %+v", err)
+	}
+}
+
+func TestClientUnmatchedReply(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config    = testConfig()
+		unmatched = make(chan osc.Message)
+	)
+	config.UnmatchedReply = func(msg osc.Message) {
+		unmatched <- msg
+	}
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if err := nsmd.SendTo(c.LocalAddr(), osc.Message{
+		Address: AddressReply,
+		Arguments: osc.Arguments{
+			osc.String(AddressServerSave),
+			osc.String("Saved."),
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	case msg := <-unmatched:
+		addr, err := msg.Arguments[0].ReadString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := AddressServerSave, addr; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+}
+
+// requestingSaver sends a request to the server while it saves.
+// The client is passed on a channel since it is created after the handler.
+type requestingSaver struct {
+	minimalHandler
+
+	client chan *Client
+}
+
+func (s *requestingSaver) Save() (string, Error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c := <-s.client
+	if _, err := c.ServerSave(ctx); err != nil {
+		return "", NewError(ErrGeneral, err.Error())
+	}
+	return "saved", nil
+}
+
+func TestClientRequestFromHandler(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config  = testConfig()
+		handler = &requestingSaver{client: make(chan *Client, 1)}
+	)
+	config.Session = nil
+	config.Handler = handler
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	handler.client <- c
+
+	_ = nsmd.OpenSession(testOpenMessage)
+
+	reply := nsmd.SaveSession(osc.Message{Address: AddressClientSave})
+
+	message, err := reply.Arguments[1].ReadString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "saved", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
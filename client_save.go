@@ -0,0 +1,61 @@
+package nsm
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// handleSave handles the save message.
+func (c *Client) handleSave(msg osc.Message) error {
+	// Only one open or save runs at a time, and only once a session is open.
+	prev, nsmerr := c.begin(StateSaving)
+	if nsmerr != nil {
+		return errors.Wrap(c.handleError(AddressClientSave, nsmerr), "could not respond to "+AddressClientSave)
+	}
+	respond := func() error {
+		defer c.inflight.Done()
+
+		response, nsmerr := c.save()
+		c.end(prev, nsmerr)
+
+		if err := c.handle(AddressClientSave, response, nsmerr); err != nil {
+			return errors.Wrap(err, "could not respond to "+AddressClientSave)
+		}
+		return nil
+	}
+	// Run the operation after the ones that are queued,
+	// so the connection keeps being served while it runs.
+	c.enqueue(respond)
+	return nil
+}
+
+// save saves a session.
+func (c *Client) save() (string, Error) {
+	if s, ok := c.Handler.(ProgressSaver); ok {
+		return s.SaveProgress(c.operationContext(), c.newProgress())
+	}
+	if s, ok := c.Handler.(ContextSaver); ok {
+		return s.SaveContext(c.operationContext())
+	}
+	return c.Handler.Save()
+}
+
+// operationContext returns the context for open and save operations.
+func (c *Client) operationContext() context.Context {
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+
+	return c.opCtx
+}
+
+// abortOperations cancels the context of ongoing open and save operations.
+// Operations that start afterwards get a new context.
+func (c *Client) abortOperations() {
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+
+	c.opCancel()
+	c.opCtx, c.opCancel = context.WithCancel(c.ctx)
+}
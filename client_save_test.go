@@ -1,7 +1,9 @@
 package nsm
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/scgolang/osc"
 )
@@ -19,6 +21,8 @@ func TestClientSave(t *testing.T) {
 	c := newClient(t, config)
 	defer func() { _ = c.Close() }() // Best effort.
 
+	_ = nsmd.OpenSession(testOpenMessage)
+
 	reply := nsmd.SaveSession(osc.Message{
 		Address: AddressClientSave,
 		Arguments: osc.Arguments{
@@ -45,3 +49,91 @@ func TestClientSave(t *testing.T) {
 		t.Fatalf("expected %s, got %s", expected, got)
 	}
 }
+
+// ctxHandler blocks in SaveContext until its context is done.
+type ctxHandler struct {
+	minimalHandler
+
+	saving    chan struct{}
+	cancelled chan error
+}
+
+func (h *ctxHandler) SaveContext(ctx context.Context) (string, Error) {
+	close(h.saving)
+	<-ctx.Done()
+	h.cancelled <- ctx.Err()
+	return "", NewError(ErrGeneral, "save cancelled")
+}
+
+func TestClientSaveContextClose(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config  = testConfig()
+		handler = &ctxHandler{saving: make(chan struct{}), cancelled: make(chan error, 1)}
+	)
+	config.Session = nil
+	config.Handler = handler
+
+	c := newClient(t, config)
+
+	_ = nsmd.OpenSession(testOpenMessage)
+
+	if err := nsmd.SendTo(c.LocalAddr(), osc.Message{Address: AddressClientSave}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for save to start")
+	case <-handler.saving:
+	}
+	_ = c.Close() // Best effort.
+
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for save to be cancelled")
+	case err := <-handler.cancelled:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %+v", err)
+		}
+	}
+}
+
+func TestClientSaveContextParent(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config      = testConfig()
+		handler     = &ctxHandler{saving: make(chan struct{}), cancelled: make(chan error, 1)}
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	config.Session = nil
+	config.Handler = handler
+
+	c, err := NewClient(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }() // Best effort.
+
+	_ = nsmd.OpenSession(testOpenMessage)
+
+	if err := nsmd.SendTo(c.LocalAddr(), osc.Message{Address: AddressClientSave}); err != nil {
+		t.Fatal(err)
+	}
+	<-handler.saving
+	cancel()
+
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for save to be cancelled")
+	case err := <-handler.cancelled:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %+v", err)
+		}
+	}
+}
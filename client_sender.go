@@ -0,0 +1,67 @@
+package nsm
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// resolveSenders resolves the AllowedSenders addresses.
+func (c *Client) resolveSenders() error {
+	for _, sender := range c.AllowedSenders {
+		addr, err := net.ResolveUDPAddr(c.DialNetwork, sender)
+		if err != nil {
+			return errors.Wrap(err, "resolve allowed sender "+sender)
+		}
+		c.allowed = append(c.allowed, addr)
+	}
+	return nil
+}
+
+// allowedSender returns true if addr is the session manager's address
+// or one of the AllowedSenders.
+func (c *Client) allowedSender(addr net.Addr) bool {
+	if addr == nil {
+		return false
+	}
+	sender, ok := addr.(*net.UDPAddr)
+	if !ok {
+		var err error
+		if sender, err = net.ResolveUDPAddr(c.DialNetwork, addr.String()); err != nil {
+			return false
+		}
+	}
+	if c.serverAddr != nil && sameUDPAddr(c.serverAddr, sender) {
+		return true
+	}
+	for _, allowed := range c.allowed {
+		if sameUDPAddr(allowed, sender) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameUDPAddr returns true if sender matches addr.
+// An unspecified IP in addr (e.g. 0.0.0.0) matches any IP.
+func sameUDPAddr(addr, sender *net.UDPAddr) bool {
+	if addr.Port != sender.Port {
+		return false
+	}
+	return addr.IP.IsUnspecified() || addr.IP.Equal(sender.IP)
+}
+
+// restrict wraps a method so that it drops messages
+// that were not sent by an allowed sender.
+func (c *Client) restrict(method osc.Method) osc.Method {
+	return func(msg osc.Message) error {
+		if !c.allowedSender(msg.Sender) {
+			if c.RejectedMessage != nil {
+				c.RejectedMessage(msg)
+			}
+			return nil
+		}
+		return method(msg)
+	}
+}
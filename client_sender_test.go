@@ -0,0 +1,174 @@
+package nsm
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+// methodsHandler provides a user method.
+type methodsHandler struct {
+	minimalHandler
+
+	called chan struct{}
+}
+
+func (h *methodsHandler) Methods() osc.Dispatcher {
+	return osc.Dispatcher{
+		"/foo": osc.Method(func(msg osc.Message) error {
+			h.called <- struct{}{}
+			return nil
+		}),
+	}
+}
+
+// newStranger creates an osc connection that is not the session manager.
+func newStranger(t *testing.T) osc.Conn {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := osc.ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestClientRejectedMessage(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	stranger := newStranger(t)
+	defer func() { _ = stranger.Close() }() // Best effort.
+
+	var (
+		config   = testConfig()
+		handler  = &methodsHandler{called: make(chan struct{})}
+		rejected = make(chan osc.Message, 1)
+	)
+	config.Session = nil
+	config.Handler = handler
+	config.AllowedSenders = []string{"127.0.0.1:9"} // Listen for messages from anywhere.
+	config.ListenAddr = "127.0.0.1:0"
+	config.RejectedMessage = func(msg osc.Message) {
+		rejected <- msg
+	}
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if err := stranger.SendTo(c.LocalAddr(), testOpenMessage); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for message to be rejected")
+	case msg := <-rejected:
+		if expected, got := AddressClientOpen, msg.Address; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	if _, ok := c.CurrentSession(); ok {
+		t.Fatal("expected no session to be open")
+	}
+
+	// User methods accept messages from anywhere.
+	if err := stranger.SendTo(c.LocalAddr(), osc.Message{Address: "/foo"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for user method")
+	case <-handler.called:
+	}
+}
+
+func TestClientAllowedSenders(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	stranger := newStranger(t)
+	defer func() { _ = stranger.Close() }() // Best effort.
+
+	config := testConfig()
+	config.AllowedSenders = []string{stranger.LocalAddr().String()}
+	config.ListenAddr = "127.0.0.1:0"
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	<-nsmd.announceAcked
+	if err := stranger.SendTo(c.LocalAddr(), testOpenMessage); err != nil {
+		t.Fatal(err)
+	}
+	// The reply goes to the session manager.
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for open reply")
+	case <-nsmd.openChan:
+	}
+}
+
+func TestClientRestrictMethods(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	stranger := newStranger(t)
+	defer func() { _ = stranger.Close() }() // Best effort.
+
+	var (
+		config   = testConfig()
+		handler  = &methodsHandler{called: make(chan struct{})}
+		rejected = make(chan osc.Message, 1)
+	)
+	config.Session = nil
+	config.Handler = handler
+	config.AllowedSenders = []string{"127.0.0.1:9"} // Listen for messages from anywhere.
+	config.ListenAddr = "127.0.0.1:0"
+	config.RestrictMethods = true
+	config.RejectedMessage = func(msg osc.Message) {
+		rejected <- msg
+	}
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if err := stranger.SendTo(c.LocalAddr(), osc.Message{Address: "/foo"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for message to be rejected")
+	case msg := <-rejected:
+		if expected, got := "/foo", msg.Address; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	// The session manager can still call user methods.
+	if err := nsmd.SendTo(c.LocalAddr(), osc.Message{Address: "/foo"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for user method")
+	case <-handler.called:
+	}
+}
+
+func TestClientBadAllowedSender(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	config := testConfig()
+	config.AllowedSenders = []string{"garbage"}
+
+	if _, err := NewClient(context.Background(), config); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
@@ -0,0 +1,149 @@
+package nsm
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// ErrServerControl is returned by the server control methods
+// if the server did not advertise CapServerControl.
+var ErrServerControl = errors.New("server does not support " + string(CapServerControl))
+
+// ErrClosed is returned when waiting for a reply from a closed client.
+var ErrClosed = errors.New("client closed")
+
+// ServerAdd asks the server to launch an executable and add it to the current session.
+func (c *Client) ServerAdd(ctx context.Context, executable string) (string, error) {
+	return c.serverControl(ctx, AddressServerAdd, osc.String(executable))
+}
+
+// ServerKill asks the server to stop the client with the provided ID.
+// The client stays in the current session.
+func (c *Client) ServerKill(ctx context.Context, clientID string) (string, error) {
+	return c.serverControl(ctx, AddressServerKill, osc.String(clientID))
+}
+
+// ServerRemove asks the server to stop the client with the provided ID
+// and remove it from the current session.
+func (c *Client) ServerRemove(ctx context.Context, clientID string) (string, error) {
+	return c.serverControl(ctx, AddressServerRemove, osc.String(clientID))
+}
+
+// ServerSave asks the server to save the current session.
+func (c *Client) ServerSave(ctx context.Context) (string, error) {
+	return c.serverControl(ctx, AddressServerSave)
+}
+
+// ServerOpen asks the server to open the session with the provided name.
+func (c *Client) ServerOpen(ctx context.Context, name string) (string, error) {
+	return c.serverControl(ctx, AddressServerOpen, osc.String(name))
+}
+
+// ServerNew asks the server to create a new session with the provided name.
+func (c *Client) ServerNew(ctx context.Context, name string) (string, error) {
+	return c.serverControl(ctx, AddressServerNew, osc.String(name))
+}
+
+// ServerDuplicate asks the server to duplicate the current session
+// to a new session with the provided name.
+func (c *Client) ServerDuplicate(ctx context.Context, name string) (string, error) {
+	return c.serverControl(ctx, AddressServerDuplicate, osc.String(name))
+}
+
+// ServerClose asks the server to save and close the current session.
+func (c *Client) ServerClose(ctx context.Context) (string, error) {
+	return c.serverControl(ctx, AddressServerClose)
+}
+
+// ServerAbort asks the server to close the current session without saving.
+func (c *Client) ServerAbort(ctx context.Context) (string, error) {
+	return c.serverControl(ctx, AddressServerAbort)
+}
+
+// ServerQuit asks the server to close the current session and exit.
+// The server does not reply to this message.
+func (c *Client) ServerQuit(ctx context.Context) error {
+	if !c.ServerInfo().Capabilities.Contains(CapServerControl) {
+		return ErrServerControl
+	}
+	return errors.Wrap(c.Send(osc.Message{Address: AddressServerQuit}), "send quit message")
+}
+
+// ListSessions asks the server for the names of all the sessions it knows about.
+// The server sends one reply per session followed by a reply with an empty name.
+func (c *Client) ListSessions(ctx context.Context) ([]string, error) {
+	names := []string{}
+
+	err := c.serverRequest(ctx, AddressServerSessions, nil, func(reply osc.Message) (bool, error) {
+		name, err := readReply(reply)
+		if err != nil {
+			return true, err
+		}
+		if name == "" {
+			return true, nil
+		}
+		names = append(names, name)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// serverControl sends a server control message and waits for the reply.
+// It returns the message from the server's reply.
+// If the server replies with an error it is returned as an Error.
+func (c *Client) serverControl(ctx context.Context, address string, args ...osc.Argument) (string, error) {
+	var message string
+
+	err := c.serverRequest(ctx, address, args, func(reply osc.Message) (bool, error) {
+		var err error
+		message, err = readReply(reply)
+		return true, err
+	})
+	return message, err
+}
+
+// serverRequest sends a server control message and calls handle with every
+// reply to it until handle returns true or an error.
+func (c *Client) serverRequest(ctx context.Context, address string, args osc.Arguments, handle func(osc.Message) (bool, error)) error {
+	if !c.ServerInfo().Capabilities.Contains(CapServerControl) {
+		return ErrServerControl
+	}
+	return c.request(ctx, osc.Message{Address: address, Arguments: args}, handle)
+}
+
+// readReply reads the message from a /reply message.
+// If msg is an /error message then the error is returned as an Error.
+func readReply(msg osc.Message) (string, error) {
+	if msg.Address == AddressError {
+		return "", readError(msg)
+	}
+	if got := len(msg.Arguments); got < 2 {
+		return "", errors.Errorf("expected 2 arguments in reply, got %d", got)
+	}
+	message, err := msg.Arguments[1].ReadString()
+	if err != nil {
+		return "", errors.Wrap(err, "read reply message")
+	}
+	return message, nil
+}
+
+// readError converts an /error message to an Error.
+func readError(msg osc.Message) error {
+	if got := len(msg.Arguments); got != 3 {
+		return errors.Errorf("expected 3 arguments in error, got %d", got)
+	}
+	code, err := msg.Arguments[1].ReadInt32()
+	if err != nil {
+		return errors.Wrap(err, "read error code")
+	}
+	message, err := msg.Arguments[2].ReadString()
+	if err != nil {
+		return errors.Wrap(err, "read error message")
+	}
+	return NewError(Code(code), message)
+}
@@ -0,0 +1,79 @@
+package nsm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/scgolang/osc"
+)
+
+func TestClientServerControl(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{
+		listenAddr: "127.0.0.1:0",
+		sessions:   []string{"band/gig", "practice"},
+	})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	c := newClient(t, testConfig())
+	defer func() { _ = c.Close() }() // Best effort.
+
+	ctx := context.Background()
+
+	message, err := c.ServerSave(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "Saved.", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if _, err := c.ServerOpen(ctx, "practice"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ServerOpen(ctx, "missing")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	nsmErr, ok := err.(Error)
+	if !ok {
+		t.Fatalf("expected Error, got %T", err)
+	}
+	if expected, got := ErrNoSuchFile, nsmErr.Code(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	sessions, err := c.ListSessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []string{"band/gig", "practice"}, sessions; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestClientServerControlNotSupported(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{
+		listenAddr: "127.0.0.1:0",
+		announceReply: osc.Message{
+			Address: AddressReply,
+			Arguments: osc.Arguments{
+				osc.String(AddressServerAnnounce),
+				osc.String("session started"),
+				osc.String("mock_nsmd"),
+				osc.String(Capabilities{CapServerBroadcast}.String()),
+			},
+		},
+	})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	c := newClient(t, testConfig())
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if _, err := c.ServerSave(context.Background()); err != ErrServerControl {
+		t.Fatalf("expected ErrServerControl, got %+v", err)
+	}
+	if _, err := c.ListSessions(context.Background()); err != ErrServerControl {
+		t.Fatalf("expected ErrServerControl, got %+v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package nsm
+
+import (
+	"os"
+	"os/signal"
+
+	"github.com/pkg/errors"
+)
+
+// HandleSignals makes the client handle the signals that the NSM API
+// defines for clients.
+// When the process receives SIGTERM or SIGINT the client is closed
+// without saving, as the NSM API requires, and Wait returns a *StopError
+// with StopSignal as the reason.
+// If SaveOnSignal is set, a standalone client saves its session when the
+// process receives SIGUSR1 (LADISH level 1). Errors from these saves are
+// ignored, so the Handler should report them itself.
+// SIGUSR1 is not handled on platforms that do not have it.
+func (c *Client) HandleSignals() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, c.handledSignals()...)
+
+	c.Go(func() error {
+		defer signal.Stop(sigs)
+
+		for {
+			select {
+			case <-c.closedChan:
+				return nil
+			case <-c.ctx.Done():
+				return c.ctx.Err()
+			case sig := <-sigs:
+				if isSaveSignal(sig) {
+					_ = c.Save() // Best effort.
+					continue
+				}
+				c.signalMu.Lock()
+				c.signal = sig
+				c.signalMu.Unlock()
+
+				return errors.Wrap(c.Close(), "close client")
+			}
+		}
+	})
+}
+
+// handledSignals returns the signals that HandleSignals listens for.
+func (c *Client) handledSignals() []os.Signal {
+	sigs := quitSignals
+	if c.SaveOnSignal && c.standalone && saveSignal != nil {
+		sigs = append([]os.Signal{saveSignal}, sigs...)
+	}
+	return sigs
+}
+
+// isSaveSignal returns true if sig is the signal that triggers a save.
+func isSaveSignal(sig os.Signal) bool {
+	return saveSignal != nil && sig == saveSignal
+}
+
+// stopSignal returns the signal that stopped the client, if any.
+func (c *Client) stopSignal() os.Signal {
+	c.signalMu.Lock()
+	defer c.signalMu.Unlock()
+
+	return c.signal
+}
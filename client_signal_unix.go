@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package nsm
+
+import (
+	"os"
+	"syscall"
+)
+
+// quitSignals make a client quit without saving.
+var quitSignals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+
+// saveSignal makes a standalone client save.
+var saveSignal os.Signal = syscall.SIGUSR1
@@ -0,0 +1,90 @@
+//go:build !windows
+// +build !windows
+
+package nsm
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// signalHandler records saves and cleanups.
+type signalHandler struct {
+	minimalHandler
+
+	saved   chan struct{}
+	cleaned chan struct{}
+}
+
+func (h *signalHandler) Save() (string, Error) {
+	h.saved <- struct{}{}
+	return "saved", nil
+}
+
+func (h *signalHandler) Cleanup() error {
+	close(h.cleaned)
+	return nil
+}
+
+func TestClientHandleSignals(t *testing.T) {
+	if err := os.Unsetenv(NsmURL); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		config  = testConfig()
+		handler = &signalHandler{
+			saved:   make(chan struct{}),
+			cleaned: make(chan struct{}),
+		}
+	)
+	config.Session = nil
+	config.Handler = handler
+	config.Standalone = true
+	config.SaveOnSignal = true
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	c.HandleSignals()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for save")
+	case <-handler.saved:
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	errChan := make(chan error)
+	go func() {
+		errChan <- c.Wait()
+	}()
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for client to stop")
+	case err := <-errChan:
+		se, ok := err.(*StopError)
+		if !ok {
+			t.Fatalf("expected a *StopError, got %+v", err)
+		}
+		if expected, got := StopSignal, se.Reason; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+		if expected, got := os.Signal(syscall.SIGTERM), se.Signal; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	select {
+	case <-handler.cleaned:
+	default:
+		t.Fatal("expected handler to be cleaned up")
+	}
+	if expected, got := StateClosed, c.State(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package nsm
+
+import (
+	"os"
+	"syscall"
+)
+
+// quitSignals make a client quit without saving.
+var quitSignals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+
+// saveSignal makes a standalone client save.
+// There is no SIGUSR1 on windows.
+var saveSignal os.Signal
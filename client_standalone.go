@@ -0,0 +1,75 @@
+package nsm
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// ErrNotStandalone is returned by Save if the client is not in standalone mode.
+var ErrNotStandalone = errors.New("client is not in standalone mode")
+
+// initializeStandalone initializes a client that is not managed by a session manager.
+// The OSC server is still started so that the Session's methods can be used,
+// then the Session is opened with the configured project path.
+func (c *Client) initializeStandalone() error {
+	laddr, err := net.ResolveUDPAddr(c.DialNetwork, c.ListenAddr)
+	if err != nil {
+		return errors.Wrap(err, "resolve udp listening address")
+	}
+	conn, err := osc.ListenUDPContext(c.ctx, c.DialNetwork, laddr)
+	if err != nil {
+		return errors.Wrap(err, "listen udp")
+	}
+	c.Conn = conn
+	c.standalone = true
+
+	// Start the OSC server.
+	c.StartOSC()
+
+	// Open the project.
+	prev, nsmerr := c.begin(StateOpening)
+	if nsmerr == nil {
+		_, nsmerr = c.open(SessionInfo{
+			ProjectPath: c.ProjectPath,
+			DisplayName: c.Name,
+			ClientID:    c.Name,
+			LocalAddr:   c.LocalAddr(),
+		})
+		c.end(prev, nsmerr)
+		c.inflight.Done()
+	}
+	if nsmerr != nil {
+		_ = c.Close() // Best effort.
+		return errors.Wrap(nsmerr, "open project")
+	}
+	return nil
+}
+
+// IsStandalone returns true if the client is running without a session manager.
+func (c *Client) IsStandalone() bool {
+	return c.standalone
+}
+
+// Save saves the Session of a standalone client.
+// The Error returned by the Session (if any) is returned as is.
+// If the client is busy with another save then the Error has the code ErrNotNow.
+// If the client is managed by a session manager then ErrNotStandalone is returned.
+func (c *Client) Save() error {
+	if !c.standalone {
+		return ErrNotStandalone
+	}
+	prev, nsmerr := c.begin(StateSaving)
+	if nsmerr != nil {
+		return nsmerr
+	}
+	_, nsmerr = c.save()
+	c.end(prev, nsmerr)
+	c.inflight.Done()
+
+	if nsmerr != nil {
+		return nsmerr
+	}
+	return nil
+}
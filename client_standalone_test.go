@@ -0,0 +1,84 @@
+package nsm
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestClientStandalone(t *testing.T) {
+	if err := os.Unsetenv(NsmURL); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		config  = testConfig()
+		session = &mockSession{
+			save: mockReply{Err: NewError(ErrGeneral, "disk full")},
+		}
+	)
+	config.Session = session
+	config.Standalone = true
+	config.ProjectPath = "./test-projects/standalone"
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if !c.IsStandalone() {
+		t.Fatal("expected client to be standalone")
+	}
+	if expected, got := config.ProjectPath, session.ProjectPath; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := config.Name, session.ClientID; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	err := c.Save()
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if code, ok := ErrorCode(err); !ok || code != ErrGeneral {
+		t.Fatalf("expected error code %d, got %+v", ErrGeneral, err)
+	}
+	session.save = mockReply{Message: "saved"}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientStandaloneOpenError(t *testing.T) {
+	if err := os.Unsetenv(NsmURL); err != nil {
+		t.Fatal(err)
+	}
+	config := testConfig()
+	config.Session = &mockSession{
+		open: mockReply{Err: NewError(ErrBadProject, "bad project")},
+	}
+	config.Standalone = true
+
+	_, err := NewClient(context.Background(), config)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if expected, got := `initialize client: open project: bad project`, err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestClientSaveNotStandalone(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	config := testConfig()
+	config.Standalone = true
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if c.IsStandalone() {
+		t.Fatal("expected client to be managed by the session manager")
+	}
+	if err := c.Save(); err != ErrNotStandalone {
+		t.Fatalf("expected ErrNotStandalone, got %+v", err)
+	}
+}
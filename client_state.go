@@ -0,0 +1,150 @@
+package nsm
+
+import (
+	"strconv"
+)
+
+// State is the lifecycle state of a client.
+type State int
+
+// Client states.
+const (
+	StateAnnouncing State = iota // The announce message has been sent.
+	StateAnnounced               // The server replied to the announce message.
+	StateOpening                 // The Handler is opening a session.
+	StateOpen                    // A session is open.
+	StateSaving                  // The Handler is saving the session.
+	StateClosed                  // The client has been closed.
+)
+
+// stateNames are the names of the client states.
+var stateNames = map[State]string{
+	StateAnnouncing: "announcing",
+	StateAnnounced:  "announced",
+	StateOpening:    "opening",
+	StateOpen:       "open",
+	StateSaving:     "saving",
+	StateClosed:     "closed",
+}
+
+// String returns the name of the state.
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return "State(" + strconv.Itoa(int(s)) + ")"
+}
+
+// StateBufferSize is the size of the channels returned by SubscribeState.
+var StateBufferSize = 16
+
+// State returns the current state of the client.
+func (c *Client) State() State {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	return c.state
+}
+
+// SubscribeState returns a channel that receives every state change.
+// The channel is buffered (see StateBufferSize) and state changes
+// are dropped if the subscriber does not keep up.
+// The channel is closed after the client enters StateClosed.
+func (c *Client) SubscribeState() <-chan State {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	ch := make(chan State, StateBufferSize)
+	if c.state == StateClosed {
+		ch <- StateClosed
+		close(ch)
+		return ch
+	}
+	c.stateSubs = append(c.stateSubs, ch)
+	return ch
+}
+
+// setState changes the state of the client and notifies subscribers.
+// A closed client never changes state again.
+func (c *Client) setState(state State) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.setStateLocked(state)
+}
+
+// setStateLocked changes the state of the client.
+// stateMu must be held by the caller.
+func (c *Client) setStateLocked(state State) {
+	if c.state == StateClosed || c.state == state {
+		return
+	}
+	c.state = state
+
+	for _, ch := range c.stateSubs {
+		select {
+		case ch <- state:
+		default:
+		}
+	}
+	if state == StateClosed {
+		for _, ch := range c.stateSubs {
+			close(ch)
+		}
+		c.stateSubs = nil
+	}
+}
+
+// announced moves the client out of StateAnnouncing.
+// Announcing again (e.g. after the server restarts) does not change the state
+// of a client that has already opened a session.
+func (c *Client) announced() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	if c.state == StateAnnouncing {
+		c.setStateLocked(StateAnnounced)
+	}
+}
+
+// begin starts an open or save operation.
+// It returns the state the client was in so that it can be restored
+// if the operation fails, or an Error if the operation is not allowed
+// in the current state.
+// Only one open or save operation runs at a time, and a client
+// without CapClientSwitch only opens a session once.
+// An operation that began is counted as in-flight until the caller
+// calls c.inflight.Done, after replying to the session manager.
+func (c *Client) begin(op State) (State, Error) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	prev := c.state
+
+	switch prev {
+	case StateOpening, StateSaving, StateClosed:
+		return prev, NewError(ErrNotNow, "client is "+prev.String())
+	case StateAnnouncing, StateAnnounced:
+		if op == StateSaving {
+			return prev, NewError(ErrNoSessionOpen, "no session is open")
+		}
+	case StateOpen:
+		if op == StateOpening && !c.Capabilities.Contains(CapClientSwitch) {
+			return prev, NewError(ErrGeneral, "client does not support switching sessions")
+		}
+	}
+	c.setStateLocked(op)
+	c.inflight.Add(1)
+
+	return prev, nil
+}
+
+// end ends an open or save operation that was started with begin.
+// If the operation failed the client goes back to the state it was in before.
+func (c *Client) end(prev State, nsmerr Error) {
+	if nsmerr != nil {
+		c.setState(prev)
+		return
+	}
+	c.setState(StateOpen)
+}
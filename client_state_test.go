@@ -0,0 +1,128 @@
+package nsm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+// slowSaver saves in the background until it is released.
+type slowSaver struct {
+	minimalHandler
+
+	release chan struct{}
+}
+
+func (h *slowSaver) SaveProgress(ctx context.Context, progress *Progress) (string, Error) {
+	<-h.release
+	return "saved", nil
+}
+
+// expectState fails the test if the next state change is not the expected one.
+func expectState(t *testing.T, states <-chan State, expected State) {
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for state %s", expected)
+	case got := <-states:
+		if expected != got {
+			t.Fatalf("expected state %s, got %s", expected, got)
+		}
+	}
+}
+
+func TestStateString(t *testing.T) {
+	for _, testcase := range []struct {
+		state    State
+		expected string
+	}{
+		{state: StateAnnouncing, expected: "announcing"},
+		{state: StateSaving, expected: "saving"},
+		{state: StateClosed, expected: "closed"},
+		{state: State(42), expected: "State(42)"},
+	} {
+		if got := testcase.state.String(); testcase.expected != got {
+			t.Fatalf("expected %s, got %s", testcase.expected, got)
+		}
+	}
+}
+
+func TestClientState(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	c := newClient(t, testConfig())
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if expected, got := StateAnnounced, c.State(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	states := c.SubscribeState()
+
+	// Saving before a session is open fails.
+	nsmErr := nsmd.SaveSessionError(osc.Message{Address: AddressClientSave})
+	if expected, got := ErrNoSessionOpen, nsmErr.Code(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	_ = nsmd.OpenSession(testOpenMessage)
+
+	expectState(t, states, StateOpening)
+	expectState(t, states, StateOpen)
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expectState(t, states, StateClosed)
+
+	if _, ok := <-states; ok {
+		t.Fatal("expected state channel to be closed")
+	}
+	if expected, got := StateClosed, c.State(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if _, ok := <-c.SubscribeState(); !ok {
+		t.Fatal("expected closed client to send StateClosed")
+	}
+}
+
+func TestClientStateNotNow(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config  = testConfig()
+		handler = &slowSaver{release: make(chan struct{})}
+	)
+	config.Session = nil
+	config.Handler = handler
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	_ = nsmd.OpenSession(testOpenMessage)
+
+	states := c.SubscribeState()
+	if err := nsmd.SendTo(c.LocalAddr(), osc.Message{Address: AddressClientSave}); err != nil {
+		t.Fatal(err)
+	}
+	expectState(t, states, StateSaving)
+
+	// Open and save are rejected while the client is saving.
+	if nsmErr := nsmd.SaveSessionError(osc.Message{Address: AddressClientSave}); nsmErr.Code() != ErrNotNow {
+		t.Fatalf("expected %d, got %d", ErrNotNow, nsmErr.Code())
+	}
+	if nsmErr := nsmd.OpenSessionError(testOpenMessage); nsmErr.Code() != ErrNotNow {
+		t.Fatalf("expected %d, got %d", ErrNotNow, nsmErr.Code())
+	}
+	close(handler.release)
+
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for save reply")
+	case <-nsmd.saveChan:
+	}
+	expectState(t, states, StateOpen)
+}
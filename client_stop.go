@@ -0,0 +1,145 @@
+package nsm
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StopReason tells why a client stopped.
+type StopReason int
+
+// Stop reasons.
+const (
+	StopClosed  StopReason = iota // Close was called.
+	StopContext                   // The context passed to NewClient is done.
+	StopFailed                    // One of the client's goroutines returned an error.
+	StopSignal                    // The process received a signal, see Client.HandleSignals.
+)
+
+// String returns a description of the stop reason.
+func (r StopReason) String() string {
+	switch r {
+	case StopClosed:
+		return "closed"
+	case StopContext:
+		return "context done"
+	case StopFailed:
+		return "failed"
+	case StopSignal:
+		return "signal"
+	}
+	return "unknown"
+}
+
+// StopError is returned by Client.Wait when the client stopped
+// for any other reason than a call to Close.
+// The error message is the message of the underlying error.
+type StopError struct {
+	Reason StopReason
+	Err    error
+
+	// Signal is the signal the process received if Reason is StopSignal.
+	Signal os.Signal
+}
+
+// Error returns the message of the underlying error.
+func (e *StopError) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the underlying error, see github.com/pkg/errors.
+func (e *StopError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the underlying error.
+func (e *StopError) Unwrap() error {
+	return e.Err
+}
+
+// close shuts the client down.
+// New open and save operations are rejected, ongoing ones are
+// cancelled and the client waits (for at most Timeout) for them
+// to finish, then the Handler cleans up (see Cleaner) and the
+// connection is closed. If the operations do not finish in time
+// the Handler does not clean up and ErrTimeout is returned.
+func (c *Client) close() error {
+	c.setState(StateClosed)
+
+	c.opMu.Lock()
+	c.opCancel()
+	c.opMu.Unlock()
+
+	close(c.closedChan)
+
+	var cleanupErr error
+	if !c.drain(c.Timeout) {
+		cleanupErr = errors.Wrap(ErrTimeout, "wait for open and save operations")
+	} else if cl, ok := c.Handler.(Cleaner); ok {
+		cleanupErr = errors.Wrap(cl.Cleanup(), "cleanup")
+	}
+
+	var statusErr error
+	if c.FinalStatus != nil && c.Capabilities.Contains(CapClientMessage) {
+		statusErr = errors.Wrap(c.sendClientStatus(*c.FinalStatus), "send final status")
+	}
+	if err := c.Conn.Close(); err != nil {
+		return err
+	}
+	if cleanupErr != nil {
+		return cleanupErr
+	}
+	return statusErr
+}
+
+// drain waits for in-flight open and save operations to finish.
+// It returns false if they did not finish before the timeout.
+func (c *Client) drain(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// closed returns true if Close has been called.
+func (c *Client) closed() bool {
+	select {
+	case <-c.closedChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// stopError returns the error that Wait returns for err,
+// the error returned by the client's goroutines.
+func (c *Client) stopError(err error) error {
+	if sig := c.stopSignal(); sig != nil {
+		return &StopError{
+			Reason: StopSignal,
+			Err:    errors.New("received signal: " + sig.String()),
+			Signal: sig,
+		}
+	}
+	switch {
+	case c.closed():
+		return nil
+	case c.parent.Err() != nil:
+		if err == nil {
+			err = c.parent.Err()
+		}
+		return &StopError{Reason: StopContext, Err: err}
+	case err != nil:
+		return &StopError{Reason: StopFailed, Err: err}
+	}
+	return nil
+}
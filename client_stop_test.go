@@ -0,0 +1,165 @@
+package nsm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+func TestStopReasonString(t *testing.T) {
+	for _, testcase := range []struct {
+		reason   StopReason
+		expected string
+	}{
+		{reason: StopClosed, expected: "closed"},
+		{reason: StopContext, expected: "context done"},
+		{reason: StopFailed, expected: "failed"},
+		{reason: StopSignal, expected: "signal"},
+		{reason: StopReason(42), expected: "unknown"},
+	} {
+		if got := testcase.reason.String(); testcase.expected != got {
+			t.Fatalf("expected %s, got %s", testcase.expected, got)
+		}
+	}
+}
+
+func TestClientCloseTwice(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	c := newClient(t, testConfig())
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Wait(); err != nil {
+		t.Fatalf("expected nil, got %+v", err)
+	}
+}
+
+func TestClientCloseDrain(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config  = testConfig()
+		handler = &ctxHandler{saving: make(chan struct{}), cancelled: make(chan error, 1)}
+	)
+	config.Session = nil
+	config.Handler = handler
+
+	c := newClient(t, config)
+
+	_ = nsmd.OpenSession(testOpenMessage)
+
+	if err := nsmd.SendTo(c.LocalAddr(), osc.Message{Address: AddressClientSave}); err != nil {
+		t.Fatal(err)
+	}
+	<-handler.saving
+
+	go func() {
+		// Drain the error reply.
+		<-nsmd.saveErr
+	}()
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Close returns after the save has finished.
+	select {
+	case err := <-handler.cancelled:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %+v", err)
+		}
+	default:
+		t.Fatal("expected save to be finished")
+	}
+}
+
+// stuckSaver ignores cancellation and saves until it is released.
+type stuckSaver struct {
+	minimalHandler
+
+	saving  chan struct{}
+	release chan struct{}
+	cleaned chan struct{}
+}
+
+func (h *stuckSaver) Save() (string, Error) {
+	close(h.saving)
+	<-h.release
+	return "saved", nil
+}
+
+func (h *stuckSaver) Cleanup() error {
+	close(h.cleaned)
+	return nil
+}
+
+func TestClientCloseDrainTimeout(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	var (
+		config  = testConfig()
+		handler = &stuckSaver{
+			saving:  make(chan struct{}),
+			release: make(chan struct{}),
+			cleaned: make(chan struct{}),
+		}
+	)
+	config.Session = nil
+	config.Handler = handler
+	config.Timeout = 200 * time.Millisecond
+
+	c := newClient(t, config)
+	defer close(handler.release)
+
+	_ = nsmd.OpenSession(testOpenMessage)
+
+	if err := nsmd.SendTo(c.LocalAddr(), osc.Message{Address: AddressClientSave}); err != nil {
+		t.Fatal(err)
+	}
+	<-handler.saving
+
+	if err := c.Close(); errors.Cause(err) != ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %+v", err)
+	}
+	select {
+	case <-handler.cleaned:
+		t.Fatal("expected handler not to clean up while it is saving")
+	default:
+	}
+}
+
+func TestClientCloseFinalStatus(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	config := testConfig()
+	config.Capabilities = Capabilities{CapClientMessage}
+	config.FinalStatus = &ClientStatus{Priority: 1, Message: "bye"}
+
+	c := newClient(t, config)
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for final status")
+	case status := <-nsmd.statusChan:
+		if expected, got := *config.FinalStatus, status; !expected.Equal(got) {
+			t.Fatalf("expected %+v, got %+v", expected, got)
+		}
+	}
+}
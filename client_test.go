@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/scgolang/osc"
 )
 
@@ -103,7 +104,7 @@ func TestClientGarbageNsmUrl(t *testing.T) {
 	if _, err := NewClient(context.Background(), testConfig()); err == nil {
 		t.Fatal("expected error, got nil")
 	} else {
-		if expected, got := `initialize client: dial udp: resolve udp remote address: missing port in address garbage`, err.Error(); expected != got {
+		if expected, got := `initialize client: dial udp: parse NSM_URL: split host and port: address garbage: missing port in address`, err.Error(); expected != got {
 			t.Fatalf("expected %s, got %s", expected, got)
 		}
 	}
@@ -158,12 +159,12 @@ func TestClientReplyFirstArgumentWrongAddress(t *testing.T) {
 	})
 	defer func() { _ = nsmd.Close() }() // Best effort.
 
-	if _, err := NewClient(context.Background(), testConfig()); err == nil {
-		t.Fatal("expected error, got nil")
-	} else {
-		if expected, got := `initialize client: announce app: handle announce reply: expected 4 arguments in announce reply, got 1`, err.Error(); expected != got {
-			t.Fatalf("expected %s, got %s", expected, got)
-		}
+	config := testConfig()
+	config.Timeout = 200 * time.Millisecond
+
+	// A reply to another address does not belong to the announce.
+	if _, err := NewClient(context.Background(), config); errors.Cause(err) != ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %+v", err)
 	}
 }
 
@@ -188,8 +189,11 @@ func TestClientContextTimeout(t *testing.T) {
 	case <-time.After(2 * time.Second):
 		t.Fatal("timeout")
 	case err := <-errChan:
-		if err != context.DeadlineExceeded {
+		if errors.Cause(err) != context.DeadlineExceeded {
 			t.Fatalf("expected context.DeadlineExceeded, got %+v", err)
 		}
+		if se, ok := err.(*StopError); !ok || se.Reason != StopContext {
+			t.Fatalf("expected a *StopError with reason %s, got %+v", StopContext, err)
+		}
 	}
 }
@@ -1,5 +1,9 @@
 package nsm
 
+import (
+	"github.com/pkg/errors"
+)
+
 // Code is the type of an error code.
 type Code int
 
@@ -28,6 +32,17 @@ func NewError(code Code, msg string) Error {
 	return nsmError{msg: msg, code: code}
 }
 
+// ErrorCode returns the nsm error code of err.
+// err may have been wrapped with github.com/pkg/errors.
+// If the cause of err is not an Error then false is returned.
+func ErrorCode(err error) (Code, bool) {
+	nsmErr, ok := errors.Cause(err).(Error)
+	if !ok {
+		return 0, false
+	}
+	return nsmErr.Code(), true
+}
+
 // nsmError represents an nsm-specific error.
 type nsmError struct {
 	msg  string
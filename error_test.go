@@ -0,0 +1,20 @@
+package nsm
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestErrorCode(t *testing.T) {
+	code, ok := ErrorCode(errors.Wrap(NewError(ErrBlacklisted, "go away"), "announce"))
+	if !ok {
+		t.Fatal("expected an Error")
+	}
+	if expected, got := ErrBlacklisted, code; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	if _, ok := ErrorCode(errors.New("not an nsm error")); ok {
+		t.Fatal("expected ok to be false")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -17,6 +18,12 @@ type mockNsmdConfig struct {
 
 	announcePause time.Duration
 	announceReply osc.Message
+
+	// sessions are the session names returned by the list server control message.
+	sessions []string
+
+	// dropPings is the number of pings the mock ignores before it replies.
+	dropPings int32
 }
 
 // mockNsmd mocks an nsmd server.
@@ -40,6 +47,7 @@ type mockNsmd struct {
 	guiShowingChan chan bool
 	progressChan   chan float32
 	statusChan     chan ClientStatus
+	broadcastChan  chan osc.Message
 }
 
 // newMockNsmd creates a new mock nsmd server.
@@ -62,6 +70,7 @@ func newMockNsmd(t *testing.T, config mockNsmdConfig) *mockNsmd {
 		guiShowingChan: make(chan bool),
 		progressChan:   make(chan float32),
 		statusChan:     make(chan ClientStatus),
+		broadcastChan:  make(chan osc.Message),
 	}
 	nsmd.defaults()
 	nsmd.initialize()
@@ -105,6 +114,16 @@ func (m *mockNsmd) initialize() {
 	m.Go(m.startOSC)
 }
 
+// testOpenMessage opens a test project.
+var testOpenMessage = osc.Message{
+	Address: AddressClientOpen,
+	Arguments: osc.Arguments{
+		osc.String("./test-projects"),
+		osc.String("display_name"),
+		osc.String("client_id"),
+	},
+}
+
 // OpenSession sends the provided message (which may or may not be an open message),
 // and waits for a reply with a configurable timeout.
 func (m *mockNsmd) OpenSession(msg osc.Message) osc.Message {
@@ -123,6 +142,12 @@ func (m *mockNsmd) SaveSession(msg osc.Message) osc.Message {
 	return m.serverToClient("save", m.saveChan, msg)
 }
 
+// SaveSessionError sends the provided message (which may or may not be a save message),
+// and waits for an error with a configurable timeout.
+func (m *mockNsmd) SaveSessionError(msg osc.Message) Error {
+	return m.serverToClientError("save", m.saveErr, msg)
+}
+
 // SessionLoaded triggers a session_is_loaded server to client message.
 func (m *mockNsmd) SessionLoaded() {
 	<-m.announceAcked
@@ -230,6 +255,16 @@ func (m *mockNsmd) dispatcher() osc.Dispatcher {
 		AddressReply:          m.ReplyHandler,
 		AddressServerAnnounce: m.AnnounceHandler,
 
+		AddressServerBroadcast: func(msg osc.Message) error {
+			m.broadcastChan <- msg
+			return nil
+		},
+
+		AddressPing:           m.PingHandler,
+		AddressServerOpen:     m.ServerOpenHandler,
+		AddressServerSave:     m.ServerControlHandler("Saved."),
+		AddressServerSessions: m.ServerSessionsHandler,
+
 		AddressClientGUIHidden: func(msg osc.Message) error {
 			m.guiShowingChan <- false
 			return nil
@@ -261,6 +296,64 @@ func (m *mockNsmd) AnnounceHandler(msg osc.Message) error {
 	return nil
 }
 
+// ServerControlHandler returns a handler that replies to a server control message.
+func (m *mockNsmd) ServerControlHandler(reply string) osc.Method {
+	return func(msg osc.Message) error {
+		return m.SendTo(msg.Sender, osc.Message{
+			Address: AddressReply,
+			Arguments: osc.Arguments{
+				osc.String(msg.Address),
+				osc.String(reply),
+			},
+		})
+	}
+}
+
+// PingHandler replies to pings once dropPings pings have been ignored.
+func (m *mockNsmd) PingHandler(msg osc.Message) error {
+	if atomic.AddInt32(&m.dropPings, -1) >= 0 {
+		return nil
+	}
+	return m.ServerControlHandler("")(msg)
+}
+
+// ServerOpenHandler replies with an error unless the session is in the sessions list.
+func (m *mockNsmd) ServerOpenHandler(msg osc.Message) error {
+	name, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return errors.Wrap(err, "read session name")
+	}
+	for _, session := range m.sessions {
+		if session == name {
+			return m.ServerControlHandler("Loaded.")(msg)
+		}
+	}
+	return m.SendTo(msg.Sender, osc.Message{
+		Address: AddressError,
+		Arguments: osc.Arguments{
+			osc.String(AddressServerOpen),
+			osc.Int(int32(ErrNoSuchFile)),
+			osc.String("no such session"),
+		},
+	})
+}
+
+// ServerSessionsHandler streams one reply per session followed by an empty name.
+func (m *mockNsmd) ServerSessionsHandler(msg osc.Message) error {
+	for _, name := range append(m.sessions, "") {
+		if err := m.SendTo(msg.Sender, osc.Message{
+			Address: AddressReply,
+			Arguments: osc.Arguments{
+				osc.String(AddressServerSessions),
+				osc.String(name),
+			},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *mockNsmd) ClientStatusHandler(msg osc.Message) error {
 	if len(msg.Arguments) != 2 {
 		return errors.New(AddressClientStatus + " should have exactly two arguments")
@@ -357,6 +450,13 @@ type mockSession struct {
 
 	// Extra osc methods to add to the client.
 	methods osc.Dispatcher
+
+	// Handlers for broadcast messages.
+	broadcasts osc.Dispatcher
+
+	// Server liveness notifications.
+	lostChan     chan struct{}
+	restoredChan chan ServerInfo
 }
 
 func (m *mockSession) Open(info SessionInfo) (string, Error) {
@@ -397,3 +497,21 @@ func (m *mockSession) ClientStatus() chan ClientStatus {
 func (m *mockSession) Methods() osc.Dispatcher {
 	return m.methods
 }
+
+func (m *mockSession) Broadcasts() osc.Dispatcher {
+	return m.broadcasts
+}
+
+func (m *mockSession) ServerLost() error {
+	if m.lostChan != nil {
+		m.lostChan <- struct{}{}
+	}
+	return nil
+}
+
+func (m *mockSession) ServerRestored(info ServerInfo) error {
+	if m.restoredChan != nil {
+		m.restoredChan <- info
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/scgolang/nsm"
+)
+
+// AddClient launches an executable as a new client of the session that is open.
+// The executable is launched with a new client ID, which is returned
+// (see Launch). Once the client announces itself it is added to the
+// session file and sent an open message.
+// The returned error is an nsm.Error with code nsm.ErrNoSessionOpen if
+// no session is open, nsm.ErrNoSuchFile if the executable can not be found,
+// or nsm.ErrLaunchFailed if it can not be started or does not announce itself.
+func (s *Server) AddClient(ctx context.Context, executable string) (string, error) {
+	if err := s.begin(); err != nil {
+		return "", err
+	}
+	defer s.end()
+
+	session, ok := s.Session()
+	if !ok {
+		return "", nsm.NewError(nsm.ErrNoSessionOpen, "no session is open")
+	}
+	p, err := s.Launch(executable, "")
+	if err != nil {
+		return "", err
+	}
+	c, err := s.waitForAnnounce(ctx, p.ClientID)
+	if err != nil {
+		s.stopProcess(p, s.KillTimeout)
+		return "", nsm.NewError(nsm.ErrLaunchFailed, "launch "+executable+": "+err.Error())
+	}
+	if err := s.appendSessionClient(session, c.sessionClient()); err != nil {
+		return "", nsm.NewError(nsm.ErrGeneral, err.Error())
+	}
+	if err := s.openClient(ctx, session, c); err != nil {
+		return "", err
+	}
+	return c.ID, nil
+}
+
+// appendSessionClient adds a client to the session file of a session.
+func (s *Server) appendSessionClient(session Session, sc nsm.SessionClient) error {
+	path := filepath.Join(session.Path, nsm.SessionFileName)
+
+	sf, err := nsm.ReadSessionFile(path)
+	if err != nil {
+		return err
+	}
+	sf.Clients = append(sf.Clients, sc)
+
+	return sf.WriteFile(path)
+}
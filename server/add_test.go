@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scgolang/nsm"
+)
+
+func TestServerAddClient(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	c := newTestClient(t, s, &testSession{})
+	defer func() { _ = c.Close() }() // Best effort.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	_, err := c.ServerAdd(ctx, helperExecutable)
+	expectCode(t, err, nsm.ErrNoSessionOpen)
+
+	if _, err := c.ServerNew(ctx, "gig"); err != nil {
+		t.Fatal(err)
+	}
+	message, err := c.ServerAdd(ctx, helperExecutable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "Launched.", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	session, _ := s.Session()
+
+	sf, err := nsm.ReadSessionFile(filepath.Join(session.Path, nsm.SessionFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 1, len(sf.Clients); expected != got {
+		t.Fatalf("expected %d clients, got %d", expected, got)
+	}
+	sc := sf.Clients[0]
+
+	if expected, got := helperExecutable, sc.Executable; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if _, err := os.Stat(filepath.Join(session.Path, "helper."+sc.ClientID)); err != nil {
+		t.Fatal(err)
+	}
+	// A second instance gets a different client ID.
+	id, err := s.AddClient(ctx, helperExecutable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == sc.ClientID {
+		t.Fatalf("expected a new client ID, got %s twice", id)
+	}
+}
+
+func TestServerAddClientNoSuchFile(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	if err := s.NewSession(context.Background(), "gig"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.AddClient(context.Background(), "nsm-this-executable-does-not-exist")
+	expectCode(t, err, nsm.ErrNoSuchFile)
+}
+
+func TestServerNewClientIDListed(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	// The first ID is nAAAA, the second one nAAAB.
+	calls := 0
+	randIntn = func(n int) int {
+		calls++
+		if calls == 8 {
+			return 1
+		}
+		return 0
+	}
+	defer func() { randIntn = rand.Intn }()
+
+	// nAAAA is listed by a client that is not running.
+	writeSession(t, s, "gig", nsm.SessionClient{
+		Name:       "helper",
+		Executable: helperExecutable,
+		ClientID:   "nAAAA",
+	})
+	s.setSession(&Session{Name: "gig", Path: filepath.Join(s.SessionsRoot, "gig")})
+
+	s.clientsMu.Lock()
+	id := s.newClientID()
+	s.clientsMu.Unlock()
+
+	if expected, got := "nAAAB", id; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
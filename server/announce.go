@@ -0,0 +1,100 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// handleAnnounce handles an announce message from a client.
+// Malformed announcements are answered with an error instead
+// of stopping the server.
+func (s *Server) handleAnnounce(msg osc.Message) error {
+	c, err := parseAnnounce(msg)
+	if err != nil {
+		return s.sendError(msg.Sender, nsm.AddressServerAnnounce, nsm.ErrGeneral, err.Error())
+	}
+	if c.Major != s.Major {
+		return s.sendError(msg.Sender, nsm.AddressServerAnnounce, nsm.ErrIncompatibleAPI, "incompatible API version")
+	}
+	s.clientsMu.Lock()
+	if p, ok := s.launcher.Process(c.PID); ok {
+		c.ID = p.ClientID
+		c.Process = p
+	} else if existing, ok := s.clientByAddr(msg.Sender); ok {
+		c.ID = existing.ID
+	} else {
+		c.ID = s.newClientID()
+	}
+	s.clients[c.ID] = &c
+
+	// Wake up everyone waiting for a client to announce itself.
+	close(s.announced)
+	s.announced = make(chan struct{})
+	s.clientsMu.Unlock()
+
+	return s.sendReply(msg.Sender, nsm.AddressServerAnnounce,
+		osc.String("Acknowledged as "+c.ID),
+		osc.String(s.Name),
+		osc.String(s.Capabilities.String()),
+	)
+}
+
+// parseAnnounce parses a client from an announce message.
+func parseAnnounce(msg osc.Message) (Client, error) {
+	if got := len(msg.Arguments); got != 6 {
+		return Client{}, errors.Errorf("expected 6 arguments in announce message, got %d", got)
+	}
+	name, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return Client{}, errors.Wrap(err, "read application name")
+	}
+	if err := validateName(name); err != nil {
+		return Client{}, err
+	}
+	capsRaw, err := msg.Arguments[1].ReadString()
+	if err != nil {
+		return Client{}, errors.Wrap(err, "read capabilities")
+	}
+	executable, err := msg.Arguments[2].ReadString()
+	if err != nil {
+		return Client{}, errors.Wrap(err, "read executable name")
+	}
+	if strings.ContainsAny(executable, nsm.SessionFileSep+"\r\n") {
+		return Client{}, errors.Errorf("invalid executable name %q", executable)
+	}
+	major, err := msg.Arguments[3].ReadInt32()
+	if err != nil {
+		return Client{}, errors.Wrap(err, "read api major version")
+	}
+	minor, err := msg.Arguments[4].ReadInt32()
+	if err != nil {
+		return Client{}, errors.Wrap(err, "read api minor version")
+	}
+	pid, err := msg.Arguments[5].ReadInt32()
+	if err != nil {
+		return Client{}, errors.Wrap(err, "read pid")
+	}
+	return Client{
+		Name:         name,
+		Executable:   executable,
+		Capabilities: nsm.ParseCapabilities(capsRaw),
+		Major:        major,
+		Minor:        minor,
+		PID:          int(pid),
+		Addr:         msg.Sender,
+	}, nil
+}
+
+// validateName checks the application name of a client.
+// The name is used in the client's project path and in the session file,
+// so it must not contain path separators, the session file separator
+// or line breaks, and must not be "." or "..".
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\"+nsm.SessionFileSep+"\r\n") {
+		return errors.Errorf("invalid application name %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+func TestServerAnnounce(t *testing.T) {
+	s := newServer(t, Config{
+		Name:         "test_server",
+		Capabilities: nsm.Capabilities{nsm.CapServerControl},
+	})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	session := &testSession{announced: make(chan nsm.ServerInfo, 1)}
+	c := newTestClient(t, s, session)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for announce reply")
+	case info := <-session.announced:
+		if expected, got := "test_server", info.ServerName; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+		if expected, got := (nsm.Capabilities{nsm.CapServerControl}), info.Capabilities; !expected.Equal(got) {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	clients := s.Clients()
+	if expected, got := 1, len(clients); expected != got {
+		t.Fatalf("expected %d clients, got %d", expected, got)
+	}
+	if expected, got := "test_client", clients[0].Name; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if !clients[0].HasCapability(nsm.CapClientSwitch) {
+		t.Fatalf("expected client to have %s capability", nsm.CapClientSwitch)
+	}
+	if expected, got := 5, len(clients[0].ID); expected != got {
+		t.Fatalf("expected client ID of length %d, got %s", expected, clients[0].ID)
+	}
+}
+
+func TestServerAnnounceErrors(t *testing.T) {
+	s := newServer(t, Config{})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	for i, testcase := range []struct {
+		msg  osc.Message
+		code nsm.Code
+	}{
+		{
+			msg:  osc.Message{Address: nsm.AddressServerAnnounce},
+			code: nsm.ErrGeneral,
+		},
+		{
+			msg: osc.Message{
+				Address: nsm.AddressServerAnnounce,
+				Arguments: osc.Arguments{
+					osc.String("test_client"),
+					osc.String(""),
+					osc.String("test_client"),
+					osc.Int(APIMajor + 1),
+					osc.Int(0),
+					osc.Int(1),
+				},
+			},
+			code: nsm.ErrIncompatibleAPI,
+		},
+		{msg: announceMessage("../../x", "test_client"), code: nsm.ErrGeneral},
+		{msg: announceMessage("test:client", "test_client"), code: nsm.ErrGeneral},
+		{msg: announceMessage("test\nclient", "test_client"), code: nsm.ErrGeneral},
+		{msg: announceMessage("..", "test_client"), code: nsm.ErrGeneral},
+		{msg: announceMessage("test_client", "test:client"), code: nsm.ErrGeneral},
+	} {
+		if expected, got := testcase.code, announceError(t, s, testcase.msg); expected != got {
+			t.Fatalf("(testcase %d) expected %d, got %d", i, expected, got)
+		}
+	}
+	if expected, got := 0, len(s.Clients()); expected != got {
+		t.Fatalf("expected %d clients, got %d", expected, got)
+	}
+}
+
+// announceMessage returns a valid announce message for the provided names.
+func announceMessage(name, executable string) osc.Message {
+	return osc.Message{
+		Address: nsm.AddressServerAnnounce,
+		Arguments: osc.Arguments{
+			osc.String(name),
+			osc.String(""),
+			osc.String(executable),
+			osc.Int(APIMajor),
+			osc.Int(APIMinor),
+			osc.Int(1),
+		},
+	}
+}
+
+// announceError sends a message to the server and returns the code of the /error reply.
+func announceError(t *testing.T, s *Server, msg osc.Message) nsm.Code {
+	raddr, err := net.ResolveUDPAddr("udp", s.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := osc.DialUDP("udp", laddr, raddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }() // Best effort.
+
+	codes := make(chan nsm.Code, 1)
+	go func() {
+		_ = conn.Serve(1, osc.Dispatcher{
+			nsm.AddressError: osc.Method(func(msg osc.Message) error {
+				code, err := msg.Arguments[1].ReadInt32()
+				if err != nil {
+					return err
+				}
+				codes <- nsm.Code(code)
+				return nil
+			}),
+		})
+	}()
+	if err := conn.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for error reply")
+	case code := <-codes:
+		return code
+	}
+	return 0
+}
+
+func TestServerAnnounceIncompatibleClient(t *testing.T) {
+	s := newServer(t, Config{})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	_, err := nsm.NewClient(context.Background(), nsm.ClientConfig{
+		Name:                 "test_client",
+		Major:                APIMajor + 1,
+		Session:              &testSession{},
+		NsmURL:               s.URL(),
+		WaitForAnnounceReply: true,
+	})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if code, ok := nsm.ErrorCode(err); !ok || code != nsm.ErrIncompatibleAPI {
+		t.Fatalf("expected error code %d, got %+v", nsm.ErrIncompatibleAPI, err)
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// handleBroadcast relays a broadcast message to every client except the sender.
+// Malformed broadcasts and broadcasts to reserved addresses
+// (see nsm.AddressPrefix) are ignored.
+func (s *Server) handleBroadcast(msg osc.Message) error {
+	if len(msg.Arguments) < 1 {
+		return nil
+	}
+	addr, err := msg.Arguments[0].ReadString()
+	if err != nil || strings.HasPrefix(addr, nsm.AddressPrefix) {
+		return nil
+	}
+	relay := osc.Message{
+		Address:   addr,
+		Arguments: msg.Arguments[1:],
+	}
+	for _, c := range s.Clients() {
+		if c.Addr == nil || (msg.Sender != nil && c.Addr.String() == msg.Sender.String()) {
+			continue
+		}
+		if err := s.SendTo(c.Addr, relay); err != nil {
+			return errors.Wrap(err, "relay broadcast to "+c.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+func TestServerBroadcast(t *testing.T) {
+	s := newServer(t, Config{})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	var (
+		senderTempo   = make(chan float32, 1)
+		receiverTempo = make(chan float32, 1)
+		tempoHandler  = func(tempos chan float32) osc.Dispatcher {
+			return osc.Dispatcher{
+				"/tempo": func(msg osc.Message) error {
+					tempo, err := msg.Arguments[0].ReadFloat32()
+					if err != nil {
+						return err
+					}
+					tempos <- tempo
+					return nil
+				},
+			}
+		}
+	)
+	sender := newTestClient(t, s, &testSession{broadcasts: tempoHandler(senderTempo)})
+	defer func() { _ = sender.Close() }() // Best effort.
+
+	receiver := newTestClient(t, s, &testSession{broadcasts: tempoHandler(receiverTempo)})
+	defer func() { _ = receiver.Close() }() // Best effort.
+
+	if err := sender.Broadcast(osc.Message{
+		Address:   "/tempo",
+		Arguments: osc.Arguments{osc.Float(133)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for broadcast")
+	case tempo := <-receiverTempo:
+		if expected, got := float32(133), tempo; expected != got {
+			t.Fatalf("expected %f, got %f", expected, got)
+		}
+	}
+	select {
+	case <-time.After(50 * time.Millisecond):
+	case <-senderTempo:
+		t.Fatal("sender should not receive its own broadcast")
+	}
+}
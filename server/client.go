@@ -0,0 +1,134 @@
+package server
+
+import (
+	"math/rand"
+	"net"
+	"path/filepath"
+
+	"github.com/scgolang/nsm"
+)
+
+// randIntn generates the letters of client IDs.
+// Tests replace it to generate known IDs.
+var randIntn = rand.Intn
+
+// Client represents an nsm client that is known to the server.
+type Client struct {
+	// ID is the client ID the server has assigned to the client.
+	ID string
+
+	// Name is the application name the client announced itself with.
+	Name string
+
+	// Executable is the name of the client's executable.
+	Executable string
+
+	// Capabilities are the capabilities the client announced.
+	Capabilities nsm.Capabilities
+
+	// Major and Minor are the API version the client implements.
+	Major int32
+	Minor int32
+
+	// PID is the process ID the client announced.
+	PID int
+
+	// Addr is the address of the client's OSC connection.
+	Addr net.Addr
+
+	// Process is the process the server launched for the client.
+	// It is nil if the client was started outside of the server.
+	Process *Process
+
+	// Session is the name of the session the server has opened in the client.
+	// It is empty if the client is not part of the session that is open,
+	// e.g. a controller that was started outside of the server.
+	Session string
+}
+
+// HasCapability returns true if the client announced the provided capability.
+func (c Client) HasCapability(capability nsm.Capability) bool {
+	return c.Capabilities.Contains(capability)
+}
+
+// sessionClient returns the session file entry of the client.
+// The executable of a launched client is the one it was launched with.
+func (c Client) sessionClient() nsm.SessionClient {
+	executable := c.Executable
+	if c.Process != nil {
+		executable = c.Process.Executable
+	}
+	return nsm.SessionClient{
+		Name:       c.Name,
+		Executable: executable,
+		ClientID:   c.ID,
+	}
+}
+
+// clientByAddr returns the client whose OSC connection has the provided address.
+// Callers must hold clientsMu.
+func (s *Server) clientByAddr(addr net.Addr) (*Client, bool) {
+	if addr == nil {
+		return nil, false
+	}
+	for _, c := range s.clients {
+		if c.Addr != nil && c.Addr.String() == addr.String() {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// launched returns true if there is a running process for the provided client ID.
+func (s *Server) launched(clientID string) bool {
+	for _, p := range s.launcher.Processes() {
+		if p.ClientID == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// newClientID generates a client ID that is not in use, i.e. not
+// registered, launched or listed in the file of the open session
+// (e.g. by a client that was killed).
+// Client IDs have the same form as the ones generated by Non Session Manager,
+// i.e. the letter n followed by four random upper case letters.
+// Callers must hold clientsMu.
+func (s *Server) newClientID() string {
+	const letters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	listed := s.listedClientIDs()
+
+	for {
+		id := []byte{'n', 0, 0, 0, 0}
+		for i := 1; i < len(id); i++ {
+			id[i] = letters[randIntn(len(letters))]
+		}
+		if _, exists := s.clients[string(id)]; exists {
+			continue
+		}
+		if s.launched(string(id)) || listed[string(id)] {
+			continue
+		}
+		return string(id)
+	}
+}
+
+// listedClientIDs returns the client IDs in the file of the open session.
+func (s *Server) listedClientIDs() map[string]bool {
+	ids := map[string]bool{}
+
+	session, ok := s.Session()
+	if !ok {
+		return ids
+	}
+	sf, err := nsm.ReadSessionFile(filepath.Join(session.Path, nsm.SessionFileName))
+	if err != nil {
+		return ids
+	}
+	for _, c := range sf.Clients {
+		ids[c.ClientID] = true
+	}
+	return ids
+}
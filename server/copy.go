@@ -0,0 +1,96 @@
+package server
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// copyTree copies the directory src to dst, which must not exist.
+// Regular files, directories and symbolic links are copied.
+// If progress is not nil it is called with the number of bytes
+// copied so far and the total number of bytes to copy.
+func copyTree(src, dst string, progress func(copied, total int64)) error {
+	var total int64
+
+	if err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "measure "+src)
+	}
+	var (
+		copied int64
+		report = func(n int64) {
+			copied += n
+			if progress != nil {
+				progress(copied, total)
+			}
+		}
+	)
+	report(0)
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			return errors.Wrap(os.Mkdir(target, mode.Perm()), "create directory")
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return errors.Wrap(err, "read link")
+			}
+			return errors.Wrap(os.Symlink(link, target), "create link")
+		case mode.IsRegular():
+			return copyFile(path, target, mode.Perm(), report)
+		}
+		return nil // Sockets, devices, etc. are skipped.
+	})
+}
+
+// copyFile copies a regular file and reports every chunk that is written.
+func copyFile(src, dst string, perm os.FileMode, report func(n int64)) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "open file")
+	}
+	defer func() { _ = in.Close() }() // Best effort.
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return errors.Wrap(err, "create file")
+	}
+	if _, err := io.Copy(progressWriter{Writer: out, report: report}, in); err != nil {
+		_ = out.Close() // Best effort.
+		return errors.Wrap(err, "copy "+src)
+	}
+	return errors.Wrap(out.Close(), "close file")
+}
+
+// progressWriter reports the number of bytes written to it.
+type progressWriter struct {
+	io.Writer
+
+	report func(n int64)
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.report(int64(n))
+	return n, err
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyTree(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		src = filepath.Join(dir, "src")
+		dst = filepath.Join(dir, "dst")
+	)
+	if err := os.MkdirAll(filepath.Join(src, "client.nABCD"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "session.nsm"), []byte("foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "client.nABCD", "data"), []byte("barbaz"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var copied, total int64
+
+	if err := copyTree(src, dst, func(c, t int64) {
+		copied, total = c, t
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := int64(10), total; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	if expected, got := total, copied; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dst, "client.nABCD", "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "barbaz", string(data); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if err := copyTree(src, dst, nil); err == nil {
+		t.Fatal("expected error copying to an existing directory, got nil")
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scgolang/nsm"
+)
+
+// clientPID returns the PID of the client with the provided ID.
+func clientPID(t *testing.T, s *Server, clientID string) int {
+	for _, c := range s.Clients() {
+		if c.ID == clientID {
+			return c.PID
+		}
+	}
+	t.Fatalf("no client with ID %s", clientID)
+	return 0
+}
+
+func TestServerDuplicateSession(t *testing.T) {
+	var copied, total int64
+
+	s := newSessionServer(t)
+	s.CopyProgress = func(c, t int64) {
+		copied, total = c, t
+	}
+	defer closeSessionServer(s)
+
+	writeSession(t, s, "gig",
+		nsm.SessionClient{Name: "helper", Executable: helperExecutable, ClientID: "nABCD"},
+		nsm.SessionClient{Name: "switch-helper", Executable: helperSwitchExecutable, ClientID: "nEFGH"},
+	)
+	c := newTestClient(t, s, &testSession{})
+	defer func() { _ = c.Close() }() // Best effort.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	_, err := c.ServerDuplicate(ctx, "gig-copy")
+	expectCode(t, err, nsm.ErrNoSessionOpen)
+
+	if _, err := c.ServerOpen(ctx, "gig"); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		pid       = clientPID(t, s, "nABCD")
+		switchPID = clientPID(t, s, "nEFGH")
+	)
+	message, err := c.ServerDuplicate(ctx, "gig-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "Duplicated.", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	session, _ := s.Session()
+	if expected, got := "gig-copy", session.Name; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if total == 0 || copied != total {
+		t.Fatalf("expected every byte to be copied, got %d of %d", copied, total)
+	}
+	// The data the clients saved before the copy is in the duplicate.
+	for _, dir := range []string{"helper.nABCD", "switch-helper.nEFGH"} {
+		if _, err := os.Stat(filepath.Join(session.Path, dir, "saved")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pid == clientPID(t, s, "nABCD") {
+		t.Fatal("expected client without switch capability to be restarted")
+	}
+	if expected, got := switchPID, clientPID(t, s, "nEFGH"); expected != got {
+		t.Fatalf("expected switch capable client to keep running as %d, got %d", expected, got)
+	}
+	for _, client := range s.Clients() {
+		if client.ID == "nABCD" || client.ID == "nEFGH" {
+			if expected, got := "gig-copy", client.Session; expected != got {
+				t.Fatalf("expected %s, got %s", expected, got)
+			}
+		}
+	}
+	_, err = c.ServerDuplicate(ctx, "gig")
+	expectCode(t, err, nsm.ErrCreateFailed)
+
+	// Copies inside the open session or another session are rejected
+	// before anything is written.
+	for _, name := range []string{"gig-copy/nested", "gig/nested"} {
+		_, err = c.ServerDuplicate(ctx, name)
+		expectCode(t, err, nsm.ErrCreateFailed)
+
+		if _, err := os.Stat(filepath.Join(s.SessionsRoot, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Fatalf("expected %s not to be created, got %v", name, err)
+		}
+	}
+}
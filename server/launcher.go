@@ -0,0 +1,159 @@
+package server
+
+import (
+	stderrors "errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/scgolang/nsm"
+)
+
+// Process is a client process that was started by a Launcher.
+type Process struct {
+	// ClientID is the client ID the process was launched for.
+	ClientID string
+
+	// Executable is the executable the process is running.
+	Executable string
+
+	// PID is the process ID of the running executable.
+	PID int
+
+	cmd  *exec.Cmd
+	done chan struct{}
+	err  error
+}
+
+// Done returns a channel that is closed when the process exits.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err returns the error the process exited with.
+// It returns nil until Done is closed.
+func (p *Process) Err() error {
+	select {
+	case <-p.done:
+		return p.err
+	default:
+		return nil
+	}
+}
+
+// Signal sends a signal to the process.
+func (p *Process) Signal(sig os.Signal) error {
+	return p.cmd.Process.Signal(sig)
+}
+
+// Launcher starts client executables and supervises them.
+// Every process is started with the NSM_URL environment variable
+// set to the address of the session manager.
+type Launcher struct {
+	// NsmURL is the value of NSM_URL passed to every process.
+	NsmURL string
+
+	// Stdout and Stderr are the output of every process.
+	// If they are nil the output is discarded.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Exited is an optional func that is called when a process exits.
+	Exited func(*Process)
+
+	// Command is an optional func that creates the command for an executable,
+	// e.g. to run clients in a wrapper. exec.Command is used if it is nil.
+	// If the command's Env is set NSM_URL is added to it, otherwise
+	// the process gets the environment of the server and NSM_URL.
+	Command func(executable string, args ...string) *exec.Cmd
+
+	mu    sync.Mutex
+	procs map[int]*Process
+}
+
+// NewLauncher creates a new launcher for the session manager at nsmURL.
+func NewLauncher(nsmURL string) *Launcher {
+	return &Launcher{
+		NsmURL: nsmURL,
+		procs:  map[int]*Process{},
+	}
+}
+
+// Launch starts an executable for the provided client ID.
+// If the executable can not be found on PATH or does not exist then the
+// returned error is an nsm.Error with code nsm.ErrNoSuchFile, and if it
+// can not be started the code is nsm.ErrLaunchFailed.
+func (l *Launcher) Launch(executable, clientID string, args ...string) (*Process, error) {
+	command := l.Command
+	if command == nil {
+		command = exec.Command
+	}
+	cmd := command(executable, args...)
+
+	// Keep the environment set by Command.
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, nsm.NsmURL+"="+l.NsmURL)
+	cmd.Stdout = l.Stdout
+	cmd.Stderr = l.Stderr
+
+	if err := cmd.Start(); err != nil {
+		if stderrors.Is(err, exec.ErrNotFound) || os.IsNotExist(err) {
+			return nil, nsm.NewError(nsm.ErrNoSuchFile, "launch "+executable+": "+err.Error())
+		}
+		return nil, nsm.NewError(nsm.ErrLaunchFailed, "launch "+executable+": "+err.Error())
+	}
+	p := &Process{
+		ClientID:   clientID,
+		Executable: executable,
+		PID:        cmd.Process.Pid,
+		cmd:        cmd,
+		done:       make(chan struct{}),
+	}
+	l.mu.Lock()
+	l.procs[p.PID] = p
+	l.mu.Unlock()
+
+	go l.wait(p)
+
+	return p, nil
+}
+
+// wait waits for a process to exit and reports it.
+func (l *Launcher) wait(p *Process) {
+	p.err = p.cmd.Wait()
+
+	l.mu.Lock()
+	delete(l.procs, p.PID)
+	l.mu.Unlock()
+
+	close(p.done)
+
+	if l.Exited != nil {
+		l.Exited(p)
+	}
+}
+
+// Process returns the running process with the provided PID.
+func (l *Launcher) Process(pid int) (*Process, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p, ok := l.procs[pid]
+	return p, ok
+}
+
+// Processes returns all the running processes.
+func (l *Launcher) Processes() []*Process {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	procs := make([]*Process, 0, len(l.procs))
+	for _, p := range l.procs {
+		procs = append(procs, p)
+	}
+	return procs
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/scgolang/nsm"
+)
+
+// helperArg is the argument that tells the test binary to run as a client.
+// helperSwitchArg tells it to run as a client that can switch sessions.
+const (
+	helperArg       = "nsm-helper-client"
+	helperSwitchArg = "nsm-helper-switch-client"
+)
+
+// TestHelperProcess is not a real test.
+// It is used as a client executable by tests that launch clients.
+func TestHelperProcess(t *testing.T) {
+	if len(os.Args) == 0 {
+		return
+	}
+	config := nsm.ClientConfig{
+		Name:                 "helper",
+		Major:                APIMajor,
+		Minor:                APIMinor,
+		PID:                  os.Getpid(),
+		Session:              &helperSession{},
+		WaitForAnnounceReply: true,
+	}
+	switch os.Args[len(os.Args)-1] {
+	case helperArg:
+	case helperSwitchArg:
+		config.Name = "switch-helper"
+		config.Capabilities = nsm.Capabilities{nsm.CapClientSwitch}
+		config.Session = &helperSwitchSession{}
+	default:
+		return
+	}
+	c, err := nsm.NewClient(context.Background(), config)
+	if err != nil {
+		os.Exit(1)
+	}
+	_ = c.Wait()
+	os.Exit(0)
+}
+
+// launchHelper launches the test binary as a client of the server.
+func launchHelper(t *testing.T, s *Server) *Process {
+	p, err := s.Launch(os.Args[0], "", "-test.run=^TestHelperProcess$", "--", helperArg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// waitForClient waits for the client with the provided ID to announce itself.
+func waitForClient(t *testing.T, s *Server, clientID string) Client {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, c := range s.Clients() {
+			if c.ID == clientID {
+				return c
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for client %s", clientID)
+	return Client{}
+}
+
+func TestServerLaunch(t *testing.T) {
+	s := newServer(t, Config{})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	p := launchHelper(t, s)
+
+	c := waitForClient(t, s, p.ClientID)
+	if expected, got := p.PID, c.PID; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	if c.Process != p {
+		t.Fatal("expected client to be associated with the launched process")
+	}
+	if err := p.Signal(os.Kill); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for process to exit")
+	case <-p.Done():
+	}
+	if p.Err() == nil {
+		t.Fatal("expected exit error, got nil")
+	}
+	if expected, got := 0, len(s.Clients()); expected != got {
+		t.Fatalf("expected %d clients, got %d", expected, got)
+	}
+}
+
+func TestServerLaunchNoSuchFile(t *testing.T) {
+	s := newServer(t, Config{})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	_, err := s.Launch("/this/executable/does/not/exist", "")
+	expectCode(t, err, nsm.ErrNoSuchFile)
+}
+
+func TestServerLaunchFailed(t *testing.T) {
+	s := newServer(t, Config{})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	// The file exists but is not executable.
+	executable := filepath.Join(t.TempDir(), "not-executable")
+	if err := ioutil.WriteFile(executable, []byte("not executable"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.Launch(executable, "")
+	expectCode(t, err, nsm.ErrLaunchFailed)
+}
+
+func TestServerLaunchCommandEnv(t *testing.T) {
+	s := newServer(t, Config{
+		Command: func(executable string, args ...string) *exec.Cmd {
+			cmd := exec.Command(os.Args[0], "-test.run=^$")
+			cmd.Env = []string{"NSM_TEST=1"}
+			return cmd
+		},
+	})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	p, err := s.Launch(helperExecutable, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-p.Done()
+
+	if expected, got := []string{"NSM_TEST=1", nsm.NsmURL + "=" + s.URL()}, p.cmd.Env; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestServerCloseStopsProcesses(t *testing.T) {
+	s := newServer(t, Config{})
+
+	p := launchHelper(t, s)
+	_ = waitForClient(t, s, p.ClientID)
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-p.Done():
+	default:
+		t.Fatal("expected Close to stop the launched process")
+	}
+	if expected, got := 0, len(s.launcher.Processes()); expected != got {
+		t.Fatalf("expected %d processes, got %d", expected, got)
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// Sessions returns the names of the sessions under the sessions root, sorted.
+// Every directory that contains a session file is a session, and the
+// names of nested sessions contain slashes, e.g. band/gig-2026.
+// The directories of a session and the TrashDir are not searched for more sessions.
+func (s *Server) Sessions() ([]string, error) {
+	names := []string{}
+
+	err := filepath.Walk(s.SessionsRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == s.SessionsRoot && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.IsDir() || path == s.SessionsRoot {
+			return nil
+		}
+		if path == filepath.Clean(s.TrashDir) {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, nsm.SessionFileName)); err != nil {
+			return nil
+		}
+		name, err := filepath.Rel(s.SessionsRoot, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(name))
+
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "walk sessions root")
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// handleList replies to a list message with one reply per session,
+// followed by a reply with an empty session name.
+// Errors sending the replies are ignored (see handleCommand).
+func (s *Server) handleList(msg osc.Message) error {
+	s.Go(func() error {
+		names, err := s.Sessions()
+		if err != nil {
+			_ = s.sendError(msg.Sender, msg.Address, nsm.ErrGeneral, err.Error()) // Best effort.
+			return nil
+		}
+		for _, name := range names {
+			if err := s.sendReply(msg.Sender, msg.Address, osc.String(name)); err != nil {
+				return nil // The client is gone.
+			}
+		}
+		_ = s.sendReply(msg.Sender, msg.Address, osc.String("")) // Best effort.
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServerListSessions(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	writeSession(t, s, "gig")
+	writeSession(t, s, "band/gig-2026")
+	writeSession(t, s, "band/rehearsal")
+
+	// Sessions in the trash are not listed.
+	writeSession(t, s, DefaultTrashDir+"/old")
+
+	// Directories without a session file are not sessions.
+	if err := os.MkdirAll(filepath.Join(s.SessionsRoot, "empty", "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, s, &testSession{})
+	defer func() { _ = c.Close() }() // Best effort.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sessions, err := c.ListSessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []string{"band/gig-2026", "band/rehearsal", "gig"}, sessions; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestServerListSessionsNoRoot(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	if err := os.RemoveAll(s.SessionsRoot); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, s, &testSession{})
+	defer func() { _ = c.Close() }() // Best effort.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sessions, err := c.ListSessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 0, len(sessions); expected != got {
+		t.Fatalf("expected %d sessions, got %d", expected, got)
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+)
+
+// KillClient stops the process of a client of the session that is open.
+// The process is sent SIGTERM, and killed if it has not exited after
+// Config.KillTimeout. The client stays in the session file, so it is
+// launched again the next time the session is opened.
+// The returned error is an nsm.Error with code nsm.ErrNoSessionOpen if
+// no session is open, or nsm.ErrGeneral if there is no such client or
+// it was not launched by the server.
+func (s *Server) KillClient(ctx context.Context, clientID string) error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	defer s.end()
+
+	if _, ok := s.Session(); !ok {
+		return nsm.NewError(nsm.ErrNoSessionOpen, "no session is open")
+	}
+	c, ok := s.client(clientID)
+	if !ok {
+		return nsm.NewError(nsm.ErrGeneral, "no client with ID "+clientID+" is running")
+	}
+	return s.killClient(c)
+}
+
+// RemoveClient removes a client from the session that is open.
+// The client is stopped like KillClient does if it is running, dropped
+// from the session file, and its project data is moved to Config.TrashDir.
+// The returned error is an nsm.Error with code nsm.ErrNoSessionOpen if
+// no session is open, or nsm.ErrGeneral if there is no such client.
+func (s *Server) RemoveClient(ctx context.Context, clientID string) error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	defer s.end()
+
+	session, ok := s.Session()
+	if !ok {
+		return nsm.NewError(nsm.ErrNoSessionOpen, "no session is open")
+	}
+	path := filepath.Join(session.Path, nsm.SessionFileName)
+
+	sf, err := nsm.ReadSessionFile(path)
+	if err != nil {
+		return nsm.NewError(nsm.ErrGeneral, err.Error())
+	}
+	removed, found := sf.Client(clientID)
+
+	c, running := s.client(clientID)
+	if running {
+		if err := s.killClient(c); err != nil {
+			return err
+		}
+		removed, found = c.sessionClient(), true
+	}
+	if !found {
+		return nsm.NewError(nsm.ErrGeneral, "no client with ID "+clientID+" in session "+session.Name)
+	}
+	sf.Remove(clientID)
+
+	if err := sf.WriteFile(path); err != nil {
+		return nsm.NewError(nsm.ErrGeneral, err.Error())
+	}
+	if err := s.trash(session, removed.Name+"."+removed.ClientID); err != nil {
+		return nsm.NewError(nsm.ErrGeneral, "move client data to trash: "+err.Error())
+	}
+	return nil
+}
+
+// client returns the client with the provided ID.
+func (s *Server) client(clientID string) (Client, bool) {
+	s.clientsMu.RLock()
+	defer s.clientsMu.RUnlock()
+
+	c, ok := s.clients[clientID]
+	if !ok {
+		return Client{}, false
+	}
+	return *c, true
+}
+
+// killClient stops the process of a client and removes it from the registry.
+func (s *Server) killClient(c Client) error {
+	if c.Process == nil {
+		return nsm.NewError(nsm.ErrGeneral, "client "+c.ID+" was not launched by the server")
+	}
+	s.stopProcess(c.Process, s.KillTimeout)
+
+	s.clientsMu.Lock()
+	delete(s.clients, c.ID)
+	s.clientsMu.Unlock()
+
+	return nil
+}
+
+// trash moves a directory of a session to the trash directory.
+// The directory is moved to a directory named after the session,
+// and a timestamp is added to its name so that it does not replace
+// directories that were trashed before.
+// Nothing happens if the directory does not exist.
+func (s *Server) trash(session Session, dir string) error {
+	src := filepath.Join(session.Path, dir)
+
+	if src == filepath.Clean(session.Path) || !within(src, session.Path) {
+		return errors.Errorf("%s is not inside session %s", dir, session.Name)
+	}
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	dst := filepath.Join(s.TrashDir, filepath.FromSlash(session.Name))
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, filepath.Join(dst, dir+"."+time.Now().Format("20060102T150405.000000000")))
+}
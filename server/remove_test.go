@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scgolang/nsm"
+)
+
+// sessionClientIDs returns the IDs of the clients in the session file of a session.
+func sessionClientIDs(t *testing.T, s *Server, name string) []string {
+	sf, err := nsm.ReadSessionFile(filepath.Join(s.SessionsRoot, name, nsm.SessionFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, sc := range sf.Clients {
+		ids = append(ids, sc.ClientID)
+	}
+	return ids
+}
+
+func TestServerKillRemoveClient(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	writeSession(t, s, "gig",
+		nsm.SessionClient{Name: "helper", Executable: helperExecutable, ClientID: "nABCD"},
+		nsm.SessionClient{Name: "helper", Executable: helperExecutable, ClientID: "nEFGH"},
+	)
+	c := newTestClient(t, s, &testSession{})
+	defer func() { _ = c.Close() }() // Best effort.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	_, err := c.ServerKill(ctx, "nABCD")
+	expectCode(t, err, nsm.ErrNoSessionOpen)
+
+	if _, err := c.ServerOpen(ctx, "gig"); err != nil {
+		t.Fatal(err)
+	}
+	message, err := c.ServerKill(ctx, "nABCD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "Killed.", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if _, ok := s.client("nABCD"); ok {
+		t.Fatal("expected killed client to be stopped")
+	}
+	_, err = c.ServerKill(ctx, "nABCD")
+	expectCode(t, err, nsm.ErrGeneral)
+
+	// A killed client stays in the session.
+	if _, err := c.ServerSave(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "nABCD nEFGH", strings.Join(sessionClientIDs(t, s, "gig"), " "); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	message, err = c.ServerRemove(ctx, "nEFGH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "Removed.", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if _, ok := s.client("nEFGH"); ok {
+		t.Fatal("expected removed client to be stopped")
+	}
+	if expected, got := "nABCD", strings.Join(sessionClientIDs(t, s, "gig"), " "); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if _, err := os.Stat(filepath.Join(s.SessionsRoot, "gig", "helper.nEFGH")); !os.IsNotExist(err) {
+		t.Fatalf("expected client data to be moved, got %v", err)
+	}
+	trashed, err := ioutil.ReadDir(filepath.Join(s.TrashDir, "gig"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 1, len(trashed); expected != got {
+		t.Fatalf("expected %d trashed directories, got %d", expected, got)
+	}
+	if name := trashed[0].Name(); !strings.HasPrefix(name, "helper.nEFGH.") {
+		t.Fatalf("expected trashed directory for helper.nEFGH, got %s", name)
+	}
+	// Clients that are not running can be removed too.
+	if _, err := c.ServerRemove(ctx, "nABCD"); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 0, len(sessionClientIDs(t, s, "gig")); expected != got {
+		t.Fatalf("expected %d clients, got %d", expected, got)
+	}
+	_, err = c.ServerRemove(ctx, "nABCD")
+	expectCode(t, err, nsm.ErrGeneral)
+
+	// The trash is not a session.
+	sessions, err := s.Sessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "gig", strings.Join(sessions, " "); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestServerStopProcessKill(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("processes can not ignore SIGTERM on windows")
+	}
+	s := newServer(t, Config{KillTimeout: 100 * time.Millisecond})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	p, err := s.Launch("sh", "", "-c", "trap '' TERM; exec sleep 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // Give the shell time to ignore SIGTERM.
+
+	done := make(chan struct{})
+	go func() {
+		s.stopProcess(p, s.KillTimeout)
+		close(done)
+	}()
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for process to be killed")
+	case <-done:
+	}
+	if p.Err() == nil {
+		t.Fatal("expected exit error, got nil")
+	}
+}
+
+func TestServerTrashOutsideSession(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	writeSession(t, s, "gig")
+	writeSession(t, s, "other")
+
+	session := Session{Name: "gig", Path: filepath.Join(s.SessionsRoot, "gig")}
+
+	for _, dir := range []string{"../other", ".", ""} {
+		if err := s.trash(session, dir); err == nil {
+			t.Fatalf("expected error trashing %q, got nil", dir)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(s.SessionsRoot, "other")); err != nil {
+		t.Fatal(err)
+	}
+}
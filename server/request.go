@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// pendingReply is a request that is waiting for the reply of a client.
+type pendingReply struct {
+	replies  chan osc.Message
+	progress chan struct{}
+}
+
+// replyKey identifies the reply of a client to a message the server sent it.
+func replyKey(clientID, address string) string {
+	return clientID + " " + address
+}
+
+// handleClientReply passes a /reply or /error from a client
+// to the request that is waiting for it.
+// Replies nobody is waiting for are ignored.
+func (s *Server) handleClientReply(msg osc.Message) error {
+	if len(msg.Arguments) < 1 {
+		return nil
+	}
+	address, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return nil
+	}
+	s.clientsMu.RLock()
+	c, ok := s.clientByAddr(msg.Sender)
+	s.clientsMu.RUnlock()
+
+	if !ok {
+		return nil
+	}
+	s.waitersMu.Lock()
+	waiter, ok := s.waiters[replyKey(c.ID, address)]
+	s.waitersMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	select {
+	case waiter.replies <- msg:
+	default:
+	}
+	return nil
+}
+
+// handleClientProgress restarts the timeout of the requests
+// that are waiting for the client that sent a progress message.
+func (s *Server) handleClientProgress(msg osc.Message) error {
+	s.clientsMu.RLock()
+	c, ok := s.clientByAddr(msg.Sender)
+	s.clientsMu.RUnlock()
+
+	if !ok {
+		return nil
+	}
+	prefix := replyKey(c.ID, "")
+
+	s.waitersMu.Lock()
+	defer s.waitersMu.Unlock()
+
+	for key, waiter := range s.waiters {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		select {
+		case waiter.progress <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// requestClient sends a message to a client and waits for its reply.
+// If the client replies with an /error it is returned as an nsm.Error.
+// The request times out if the client neither replies nor reports
+// its progress for Timeout.
+func (s *Server) requestClient(ctx context.Context, c Client, msg osc.Message) (string, error) {
+	var (
+		key    = replyKey(c.ID, msg.Address)
+		waiter = &pendingReply{
+			replies:  make(chan osc.Message, 1),
+			progress: make(chan struct{}, 1),
+		}
+	)
+	s.waitersMu.Lock()
+	s.waiters[key] = waiter
+	s.waitersMu.Unlock()
+
+	defer func() {
+		s.waitersMu.Lock()
+		delete(s.waiters, key)
+		s.waitersMu.Unlock()
+	}()
+
+	if err := s.SendTo(c.Addr, msg); err != nil {
+		return "", errors.Wrap(err, "send "+msg.Address)
+	}
+	timer := time.NewTimer(s.Timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", errors.Wrap(ctx.Err(), "wait for reply to "+msg.Address)
+		case <-timer.C:
+			return "", errors.Wrap(context.DeadlineExceeded, "wait for reply to "+msg.Address)
+		case <-waiter.progress:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(s.Timeout)
+		case reply := <-waiter.replies:
+			return readReply(reply)
+		}
+	}
+}
+
+// requestClients sends a message to every client and waits for the replies.
+// It returns a description of every failed request.
+func (s *Server) requestClients(ctx context.Context, clients []Client, msg func(Client) osc.Message) []string {
+	ids := make([]string, len(clients))
+	for i, c := range clients {
+		ids[i] = c.ID
+	}
+	return forEach(ids, func(i int) error {
+		_, err := s.requestClient(ctx, clients[i], msg(clients[i]))
+		return err
+	})
+}
+
+// forEach calls f concurrently for every client ID and waits for all the calls to return.
+// It returns a description of every failed call, sorted by client ID.
+func forEach(ids []string, f func(i int) error) []string {
+	var (
+		failures = make(chan string, len(ids))
+		done     = make(chan struct{}, len(ids))
+	)
+	for i := range ids {
+		go func(i int) {
+			if err := f(i); err != nil {
+				failures <- ids[i] + ": " + err.Error()
+			}
+			done <- struct{}{}
+		}(i)
+	}
+	for range ids {
+		<-done
+	}
+	close(failures)
+
+	failed := []string{}
+	for failure := range failures {
+		failed = append(failed, failure)
+	}
+	sort.Strings(failed)
+
+	return failed
+}
+
+// readReply reads the message from a client's /reply,
+// or the nsm.Error from an /error.
+func readReply(msg osc.Message) (string, error) {
+	if msg.Address == nsm.AddressError {
+		if len(msg.Arguments) != 3 {
+			return "", errors.Errorf("expected 3 arguments in error, got %d", len(msg.Arguments))
+		}
+		code, err := msg.Arguments[1].ReadInt32()
+		if err != nil {
+			return "", errors.Wrap(err, "read error code")
+		}
+		message, err := msg.Arguments[2].ReadString()
+		if err != nil {
+			return "", errors.Wrap(err, "read error message")
+		}
+		return "", nsm.NewError(nsm.Code(code), message)
+	}
+	if len(msg.Arguments) < 2 {
+		return "", errors.Errorf("expected 2 arguments in reply, got %d", len(msg.Arguments))
+	}
+	message, err := msg.Arguments[1].ReadString()
+	if err != nil {
+		return "", errors.Wrap(err, "read reply message")
+	}
+	return message, nil
+}
+
+// joinFailures formats failures from requestClients.
+func joinFailures(failures []string) string {
+	return strings.Join(failures, "; ")
+}
@@ -0,0 +1,340 @@
+// Package server implements the server side of the non session manager OSC protocol.
+//
+// See http://non.tuxfamily.org/nsm/API.html for more details.
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+	"golang.org/x/sync/errgroup"
+)
+
+// API version implemented by the server.
+const (
+	APIMajor = 1
+	APIMinor = 2
+)
+
+// DefaultName is the default name the server uses to identify itself to clients.
+var DefaultName = "nsmd"
+
+// DefaultSessionsRoot is the default directory of the sessions,
+// relative to the user's home directory.
+var DefaultSessionsRoot = "NSM Sessions"
+
+// DefaultTrashDir is the default directory the project data
+// of removed clients is moved to, relative to the sessions root.
+var DefaultTrashDir = ".trash"
+
+// DefaultKillTimeout is the default amount of time the server waits for
+// a client to exit after sending it SIGTERM, before it sends SIGKILL.
+var DefaultKillTimeout = 10 * time.Second
+
+// Config represents the configuration of an nsm server.
+type Config struct {
+	Name         string
+	Capabilities nsm.Capabilities
+	Major        int32
+	Minor        int32
+
+	// Timeout is an amount of time we should wait for a response from a client.
+	// Every progress message from the client restarts the timeout.
+	Timeout time.Duration
+
+	// KillTimeout is the amount of time the server waits for a client
+	// to exit after sending it SIGTERM, before it sends SIGKILL.
+	KillTimeout time.Duration
+
+	ListenAddr string
+	Network    string
+
+	// SessionsRoot is the directory that contains the sessions.
+	// It defaults to DefaultSessionsRoot in the user's home directory.
+	SessionsRoot string
+
+	// TrashDir is the directory the project data of removed clients is moved to.
+	// It defaults to DefaultTrashDir in the sessions root.
+	TrashDir string
+
+	// CopyProgress is an optional func that is called while a session
+	// is being duplicated, with the number of bytes copied so far
+	// and the total number of bytes to copy.
+	CopyProgress func(copied, total int64)
+
+	// Stdout and Stderr receive the output of the clients launched by the server.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Command is an optional func that creates the command
+	// for a client executable, see Launcher.
+	Command func(executable string, args ...string) *exec.Cmd
+}
+
+// Server represents an nsm server.
+type Server struct {
+	Config
+	osc.Conn
+
+	group    *errgroup.Group
+	ctx      context.Context
+	launcher *Launcher
+
+	clientsMu sync.RWMutex
+	clients   map[string]*Client
+	announced chan struct{} // Closed and replaced when a client announces itself.
+
+	sessionMu sync.Mutex
+	session   *Session
+	busy      bool
+
+	waitersMu sync.Mutex
+	waiters   map[string]*pendingReply
+}
+
+// New creates a new nsm server that is listening for client announcements.
+func New(ctx context.Context, config Config) (*Server, error) {
+	g, gctx := errgroup.WithContext(ctx)
+
+	s := &Server{
+		Config:    config,
+		group:     g,
+		ctx:       gctx,
+		clients:   map[string]*Client{},
+		announced: make(chan struct{}),
+		waiters:   map[string]*pendingReply{},
+	}
+	s.Defaults()
+
+	if err := s.Initialize(); err != nil {
+		return nil, errors.Wrap(err, "initialize server")
+	}
+	return s, nil
+}
+
+// Defaults sets default config values for the server.
+func (s *Server) Defaults() {
+	if s.Name == "" {
+		s.Name = DefaultName
+	}
+	if s.Capabilities == nil {
+		s.Capabilities = nsm.Capabilities{nsm.CapServerControl, nsm.CapServerBroadcast}
+	}
+	if s.Major == 0 {
+		s.Major = APIMajor
+		s.Minor = APIMinor
+	}
+	if s.Timeout == time.Duration(0) {
+		s.Timeout = nsm.DefaultTimeout
+	}
+	if s.KillTimeout == time.Duration(0) {
+		s.KillTimeout = DefaultKillTimeout
+	}
+	if s.ListenAddr == "" {
+		s.ListenAddr = "127.0.0.1:0"
+	}
+	if s.Network == "" {
+		s.Network = "udp"
+	}
+	if s.SessionsRoot == "" {
+		s.SessionsRoot = DefaultSessionsRoot
+		if home, err := os.UserHomeDir(); err == nil {
+			s.SessionsRoot = filepath.Join(home, DefaultSessionsRoot)
+		}
+	}
+	if s.TrashDir == "" {
+		s.TrashDir = filepath.Join(s.SessionsRoot, DefaultTrashDir)
+	}
+}
+
+// Initialize initializes the server.
+func (s *Server) Initialize() error {
+	laddr, err := net.ResolveUDPAddr(s.Network, s.ListenAddr)
+	if err != nil {
+		return errors.Wrap(err, "resolve udp listening address")
+	}
+	conn, err := osc.ListenUDPContext(s.ctx, s.Network, laddr)
+	if err != nil {
+		return errors.Wrap(err, "listen udp")
+	}
+	s.Conn = conn
+
+	s.launcher = NewLauncher(s.URL())
+	s.launcher.Stdout = s.Stdout
+	s.launcher.Stderr = s.Stderr
+	s.launcher.Exited = s.handleExit
+	s.launcher.Command = s.Command
+
+	s.Go(s.serveOSC)
+
+	return nil
+}
+
+// URL returns the value of NSM_URL that clients should use to reach the server.
+func (s *Server) URL() string {
+	u, err := nsm.URLFromAddr(s.LocalAddr())
+	if err != nil {
+		return "" // Never happens for an address the server is listening on.
+	}
+	return u.String()
+}
+
+// Go runs a goroutine as part of an errgroup.Group
+func (s *Server) Go(f func() error) {
+	s.group.Go(f)
+}
+
+// Wait waits for all the goroutines in an errgroup.Group to finish
+func (s *Server) Wait() error {
+	return s.group.Wait()
+}
+
+// Close closes the nsm server.
+// Every process the server launched is sent SIGTERM, and killed if it
+// has not exited after KillTimeout. The session that is open is not saved.
+func (s *Server) Close() error {
+	var (
+		procs = s.launcher.Processes()
+		done  = make(chan struct{})
+	)
+	for _, p := range procs {
+		go func(p *Process) {
+			s.stopProcess(p, s.KillTimeout)
+			done <- struct{}{}
+		}(p)
+	}
+	for range procs {
+		<-done
+	}
+	return s.Conn.Close()
+}
+
+// Clients returns the clients that have announced themselves to the server.
+func (s *Server) Clients() []Client {
+	s.clientsMu.RLock()
+	defer s.clientsMu.RUnlock()
+
+	clients := make([]Client, 0, len(s.clients))
+	for _, c := range s.clients {
+		clients = append(clients, *c)
+	}
+	return clients
+}
+
+// Launch starts a client executable with NSM_URL pointing to the server.
+// If clientID is empty a new client ID is generated.
+// When the process announces itself it is registered with the client ID
+// it was launched with.
+func (s *Server) Launch(executable, clientID string, args ...string) (*Process, error) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+
+	if clientID == "" {
+		clientID = s.newClientID()
+	}
+	return s.launcher.Launch(executable, clientID, args...)
+}
+
+// handleExit removes a launched client from the registry when its process exits.
+func (s *Server) handleExit(p *Process) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+
+	if c, ok := s.clients[p.ClientID]; ok && c.Process == p {
+		delete(s.clients, p.ClientID)
+	}
+}
+
+// serveOSC listens for incoming messages from nsm clients.
+func (s *Server) serveOSC() error {
+	// Arbitrary number of worker routines.
+	return s.Serve(8, s.dispatcher())
+}
+
+// dispatcher returns the osc Dispatcher for the nsm server.
+func (s *Server) dispatcher() osc.Dispatcher {
+	return osc.Dispatcher{
+		nsm.AddressServerAnnounce: osc.Method(func(msg osc.Message) error {
+			return s.handleAnnounce(msg)
+		}),
+		nsm.AddressServerBroadcast: osc.Method(func(msg osc.Message) error {
+			return s.handleBroadcast(msg)
+		}),
+		nsm.AddressPing: osc.Method(func(msg osc.Message) error {
+			return s.sendReply(msg.Sender, nsm.AddressPing)
+		}),
+		nsm.AddressReply: osc.Method(func(msg osc.Message) error {
+			return s.handleClientReply(msg)
+		}),
+		nsm.AddressError: osc.Method(func(msg osc.Message) error {
+			return s.handleClientReply(msg)
+		}),
+		nsm.AddressClientProgress: osc.Method(func(msg osc.Message) error {
+			return s.handleClientProgress(msg)
+		}),
+		nsm.AddressServerSessions: osc.Method(func(msg osc.Message) error {
+			return s.handleList(msg)
+		}),
+		nsm.AddressServerNew: osc.Method(func(msg osc.Message) error {
+			return s.handleNamedCommand(msg, "Created.", s.NewSession)
+		}),
+		nsm.AddressServerOpen: osc.Method(func(msg osc.Message) error {
+			return s.handleNamedCommand(msg, "Loaded.", s.OpenSession)
+		}),
+		nsm.AddressServerAdd: osc.Method(func(msg osc.Message) error {
+			return s.handleNamedCommand(msg, "Launched.", func(ctx context.Context, executable string) error {
+				_, err := s.AddClient(ctx, executable)
+				return err
+			})
+		}),
+		nsm.AddressServerKill: osc.Method(func(msg osc.Message) error {
+			return s.handleNamedCommand(msg, "Killed.", s.KillClient)
+		}),
+		nsm.AddressServerRemove: osc.Method(func(msg osc.Message) error {
+			return s.handleNamedCommand(msg, "Removed.", s.RemoveClient)
+		}),
+		nsm.AddressServerDuplicate: osc.Method(func(msg osc.Message) error {
+			return s.handleNamedCommand(msg, "Duplicated.", s.DuplicateSession)
+		}),
+		nsm.AddressServerSave: osc.Method(func(msg osc.Message) error {
+			return s.handleCommand(msg, "Saved.", s.SaveSession)
+		}),
+		nsm.AddressServerClose: osc.Method(func(msg osc.Message) error {
+			return s.handleCommand(msg, "Closed.", s.CloseSession)
+		}),
+		nsm.AddressServerAbort: osc.Method(func(msg osc.Message) error {
+			return s.handleCommand(msg, "Aborted.", s.AbortSession)
+		}),
+	}
+}
+
+// sendError sends an error reply for the provided address.
+func (s *Server) sendError(addr net.Addr, address string, code nsm.Code, message string) error {
+	msg := osc.Message{
+		Address: nsm.AddressError,
+		Arguments: osc.Arguments{
+			osc.String(address),
+			osc.Int(int32(code)),
+			osc.String(message),
+		},
+	}
+	return errors.Wrap(s.SendTo(addr, msg), "send error")
+}
+
+// sendReply sends a reply for the provided address.
+func (s *Server) sendReply(addr net.Addr, address string, args ...osc.Argument) error {
+	msg := osc.Message{
+		Address:   nsm.AddressReply,
+		Arguments: append(osc.Arguments{osc.String(address)}, args...),
+	}
+	return errors.Wrap(s.SendTo(addr, msg), "send reply")
+}
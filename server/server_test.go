@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// testSession is a minimal nsm.Session used to drive a client against the server.
+type testSession struct {
+	nsm.SessionInfo
+
+	announced  chan nsm.ServerInfo
+	broadcasts osc.Dispatcher
+}
+
+func (s *testSession) Announce(info nsm.ServerInfo) error {
+	if s.announced != nil {
+		s.announced <- info
+	}
+	return nil
+}
+
+func (s *testSession) Open(info nsm.SessionInfo) (string, nsm.Error) {
+	s.SessionInfo = info
+	return "opened", nil
+}
+
+func (s *testSession) Save() (string, nsm.Error) {
+	return "saved", nil
+}
+
+func (s *testSession) Broadcasts() osc.Dispatcher {
+	return s.broadcasts
+}
+
+func newServer(t *testing.T, config Config) *Server {
+	s, err := New(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newTestClient(t *testing.T, s *Server, session nsm.Session) *nsm.Client {
+	c, err := nsm.NewClient(context.Background(), nsm.ClientConfig{
+		Name:                 "test_client",
+		Capabilities:         nsm.Capabilities{nsm.CapClientSwitch},
+		Major:                APIMajor,
+		Minor:                APIMinor,
+		PID:                  os.Getpid(),
+		Session:              session,
+		NsmURL:               s.URL(),
+		WaitForAnnounceReply: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestServerDefaults(t *testing.T) {
+	s := newServer(t, Config{})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	if expected, got := DefaultName, s.Name; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := int32(APIMajor), s.Major; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+}
+
+func TestServerGarbageListenAddr(t *testing.T) {
+	if _, err := New(context.Background(), Config{ListenAddr: "garbage"}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestServerUnknownAddress(t *testing.T) {
+	s := newServer(t, Config{})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	c := newTestClient(t, s, &testSession{})
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if err := c.Send(osc.Message{Address: "/foo/bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 1, len(s.Clients()); expected != got {
+		t.Fatalf("expected %d clients, got %d", expected, got)
+	}
+}
+
+func TestServerPing(t *testing.T) {
+	s := newServer(t, Config{})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	c := newTestClient(t, s, &testSession{})
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,530 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// Session is the session that is open in a server.
+type Session struct {
+	// Name is the name of the session relative to the sessions root.
+	// The names of nested sessions contain slashes, e.g. band/gig-2026.
+	Name string
+
+	// Path is the directory of the session.
+	Path string
+}
+
+// Session returns the session that is open.
+// It returns false if no session is open.
+func (s *Server) Session() (Session, bool) {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+
+	if s.session == nil {
+		return Session{}, false
+	}
+	return *s.session, true
+}
+
+// NewSession creates a new session under the sessions root and opens it.
+// The session that is open is saved and closed first.
+// The returned error is an nsm.Error with code nsm.ErrCreateFailed
+// if the session can not be created, or nsm.ErrUnsavedChanges if the
+// session that is open could not be saved.
+func (s *Server) NewSession(ctx context.Context, name string) error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	defer s.end()
+
+	dir, err := s.sessionPath(name)
+	if err != nil {
+		return nsm.NewError(nsm.ErrCreateFailed, err.Error())
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nsm.NewError(nsm.ErrCreateFailed, "session "+name+" already exists")
+	}
+	if err := s.closeSession(ctx, true); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nsm.NewError(nsm.ErrCreateFailed, err.Error())
+	}
+	sf := &nsm.SessionFile{Clients: []nsm.SessionClient{}}
+	if err := sf.WriteFile(filepath.Join(dir, nsm.SessionFileName)); err != nil {
+		return nsm.NewError(nsm.ErrCreateFailed, err.Error())
+	}
+	s.setSession(&Session{Name: name, Path: dir})
+
+	return nil
+}
+
+// OpenSession opens the session with the provided name.
+// The session that is open is saved and closed first.
+// Every client in the session file is launched and sent an open message.
+// The returned error is an nsm.Error with code nsm.ErrNoSuchFile if
+// there is no such session, or nsm.ErrUnsavedChanges if the session
+// that is open could not be saved.
+// If some of the clients fail to start the session is still opened
+// and the returned error has the code nsm.ErrGeneral.
+func (s *Server) OpenSession(ctx context.Context, name string) error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	defer s.end()
+
+	dir, err := s.sessionPath(name)
+	if err != nil {
+		return nsm.NewError(nsm.ErrNoSuchFile, err.Error())
+	}
+	sf, err := nsm.ReadSessionFile(filepath.Join(dir, nsm.SessionFileName))
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nsm.NewError(nsm.ErrNoSuchFile, "session "+name+" does not exist")
+		}
+		return nsm.NewError(nsm.ErrBadProject, err.Error())
+	}
+	if err := s.closeSession(ctx, true); err != nil {
+		return err
+	}
+	session := Session{Name: name, Path: dir}
+	s.setSession(&session)
+
+	failures := s.launchClients(ctx, session, sf.Clients)
+	s.sessionLoaded()
+
+	if len(failures) > 0 {
+		return nsm.NewError(nsm.ErrGeneral, "session loaded with errors: "+joinFailures(failures))
+	}
+	return nil
+}
+
+// SaveSession asks every client to save and writes the session file.
+// The returned error is an nsm.Error with code nsm.ErrNoSessionOpen if
+// no session is open, or nsm.ErrGeneral if some clients failed to save.
+func (s *Server) SaveSession(ctx context.Context) error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	defer s.end()
+
+	session, ok := s.Session()
+	if !ok {
+		return nsm.NewError(nsm.ErrNoSessionOpen, "no session is open")
+	}
+	return s.saveSession(ctx, session)
+}
+
+// CloseSession saves the session that is open, then stops its clients.
+// The returned error is an nsm.Error with code nsm.ErrNoSessionOpen if
+// no session is open, or nsm.ErrUnsavedChanges if the session could not
+// be saved, in which case it stays open.
+func (s *Server) CloseSession(ctx context.Context) error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	defer s.end()
+
+	if _, ok := s.Session(); !ok {
+		return nsm.NewError(nsm.ErrNoSessionOpen, "no session is open")
+	}
+	return s.closeSession(ctx, true)
+}
+
+// AbortSession stops the clients of the session that is open without saving.
+// The returned error is an nsm.Error with code nsm.ErrNoSessionOpen if
+// no session is open.
+func (s *Server) AbortSession(ctx context.Context) error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	defer s.end()
+
+	if _, ok := s.Session(); !ok {
+		return nsm.NewError(nsm.ErrNoSessionOpen, "no session is open")
+	}
+	return s.closeSession(ctx, false)
+}
+
+// DuplicateSession saves the session that is open, copies it to a session
+// with the provided name and opens the copy.
+// Clients with the nsm.CapClientSwitch capability are sent an open message
+// for the copy, the other clients are restarted.
+// Config.CopyProgress is called while the session is being copied.
+// The returned error is an nsm.Error with code nsm.ErrNoSessionOpen if
+// no session is open, nsm.ErrUnsavedChanges if the session could not
+// be saved, or nsm.ErrCreateFailed if the copy can not be created.
+// If some of the clients fail to open the copy it is still opened
+// and the returned error has the code nsm.ErrGeneral.
+func (s *Server) DuplicateSession(ctx context.Context, name string) error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	defer s.end()
+
+	session, ok := s.Session()
+	if !ok {
+		return nsm.NewError(nsm.ErrNoSessionOpen, "no session is open")
+	}
+	dir, err := s.sessionPath(name)
+	if err != nil {
+		return nsm.NewError(nsm.ErrCreateFailed, err.Error())
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nsm.NewError(nsm.ErrCreateFailed, "session "+name+" already exists")
+	}
+	// A copy inside the session would be copied into itself.
+	if within(dir, session.Path) {
+		return nsm.NewError(nsm.ErrCreateFailed, "session "+name+" is inside session "+session.Name)
+	}
+	if err := s.saveSession(ctx, session); err != nil {
+		return nsm.NewError(nsm.ErrUnsavedChanges, err.Error())
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nsm.NewError(nsm.ErrCreateFailed, err.Error())
+	}
+	if err := copyTree(session.Path, dir, s.CopyProgress); err != nil {
+		_ = os.RemoveAll(dir) // Best effort.
+		return nsm.NewError(nsm.ErrCreateFailed, "copy session: "+err.Error())
+	}
+	duplicate := Session{Name: name, Path: dir}
+	s.setSession(&duplicate)
+
+	clients := s.sessionClients()
+	ids := make([]string, len(clients))
+	for i, c := range clients {
+		ids[i] = c.ID
+	}
+	failures := forEach(ids, func(i int) error {
+		if clients[i].HasCapability(nsm.CapClientSwitch) {
+			return s.openClient(ctx, duplicate, clients[i])
+		}
+		return s.restartClient(ctx, duplicate, clients[i])
+	})
+	s.sessionLoaded()
+
+	if len(failures) > 0 {
+		return nsm.NewError(nsm.ErrGeneral, "session duplicated with errors: "+joinFailures(failures))
+	}
+	return nil
+}
+
+// begin starts a session command.
+// Only one session command runs at a time.
+func (s *Server) begin() error {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+
+	if s.busy {
+		return nsm.NewError(nsm.ErrNotNow, "another session command is running")
+	}
+	s.busy = true
+	return nil
+}
+
+// end ends a session command.
+func (s *Server) end() {
+	s.sessionMu.Lock()
+	s.busy = false
+	s.sessionMu.Unlock()
+}
+
+// setSession sets the session that is open.
+func (s *Server) setSession(session *Session) {
+	s.sessionMu.Lock()
+	s.session = session
+	s.sessionMu.Unlock()
+}
+
+// sessionPath returns the directory of the session with the provided name.
+// Names are slash-separated paths relative to the sessions root.
+// Sessions can not be in the TrashDir or inside another session,
+// since Sessions would not list them.
+func (s *Server) sessionPath(name string) (string, error) {
+	if name == "" || path.Clean("/"+name) != "/"+name {
+		return "", errors.Errorf("invalid session name %q", name)
+	}
+	dir := filepath.Join(s.SessionsRoot, filepath.FromSlash(name))
+
+	if within(dir, s.TrashDir) {
+		return "", errors.Errorf("session %s is in the trash", name)
+	}
+	if parent, ok := s.parentSession(dir); ok {
+		return "", errors.Errorf("session %s is inside session %s", name, parent)
+	}
+	return dir, nil
+}
+
+// parentSession returns the name of the existing session
+// whose directory contains dir, if any.
+func (s *Server) parentSession(dir string) (string, bool) {
+	for parent := filepath.Dir(dir); within(parent, s.SessionsRoot) && parent != filepath.Clean(s.SessionsRoot); parent = filepath.Dir(parent) {
+		if _, err := os.Stat(filepath.Join(parent, nsm.SessionFileName)); err == nil {
+			name, err := filepath.Rel(s.SessionsRoot, parent)
+			if err != nil {
+				return "", false
+			}
+			return filepath.ToSlash(name), true
+		}
+	}
+	return "", false
+}
+
+// within returns true if path is dir or a path inside dir.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// saveSession asks every client to save and writes the session file.
+// Clients in the session file that are not running (e.g. because they
+// failed to launch, crashed or were killed) stay in the session file.
+func (s *Server) saveSession(ctx context.Context, session Session) error {
+	var (
+		clients = s.sessionClients()
+		path    = filepath.Join(session.Path, nsm.SessionFileName)
+		running = map[string]bool{}
+		sf      = &nsm.SessionFile{Clients: []nsm.SessionClient{}}
+	)
+	failures := s.requestClients(ctx, clients, func(Client) osc.Message {
+		return osc.Message{Address: nsm.AddressClientSave}
+	})
+	for _, c := range clients {
+		running[c.ID] = true
+		sf.Clients = append(sf.Clients, c.sessionClient())
+	}
+	if prev, err := nsm.ReadSessionFile(path); err == nil {
+		for _, sc := range prev.Clients {
+			if !running[sc.ClientID] {
+				sf.Clients = append(sf.Clients, sc)
+			}
+		}
+	}
+	sort.Slice(sf.Clients, func(i, j int) bool {
+		return sf.Clients[i].ClientID < sf.Clients[j].ClientID
+	})
+	if err := sf.WriteFile(path); err != nil {
+		return nsm.NewError(nsm.ErrGeneral, err.Error())
+	}
+	if len(failures) > 0 {
+		return nsm.NewError(nsm.ErrGeneral, "clients failed to save: "+joinFailures(failures))
+	}
+	return nil
+}
+
+// closeSession stops the clients of the session that is open, if any.
+// If save is true the session is saved first, and it stays open if that fails.
+func (s *Server) closeSession(ctx context.Context, save bool) error {
+	session, ok := s.Session()
+	if !ok {
+		return nil
+	}
+	if save {
+		if err := s.saveSession(ctx, session); err != nil {
+			return nsm.NewError(nsm.ErrUnsavedChanges, err.Error())
+		}
+	}
+	s.stopClients()
+	s.setSession(nil)
+
+	return nil
+}
+
+// launchClients launches the clients of a session and sends them an open message.
+// It returns a description of every client that failed to start.
+func (s *Server) launchClients(ctx context.Context, session Session, clients []nsm.SessionClient) []string {
+	ids := make([]string, len(clients))
+	for i, sc := range clients {
+		ids[i] = sc.ClientID
+	}
+	return forEach(ids, func(i int) error {
+		return s.launchClient(ctx, session, clients[i])
+	})
+}
+
+// launchClient launches a client of a session and sends it an open message
+// once it has announced itself.
+func (s *Server) launchClient(ctx context.Context, session Session, sc nsm.SessionClient) error {
+	if _, err := s.Launch(sc.Executable, sc.ClientID); err != nil {
+		return err
+	}
+	c, err := s.waitForAnnounce(ctx, sc.ClientID)
+	if err != nil {
+		return err
+	}
+	return s.openClient(ctx, session, c)
+}
+
+// restartClient stops a client and launches it again in the provided session.
+func (s *Server) restartClient(ctx context.Context, session Session, c Client) error {
+	if c.Process != nil {
+		s.stopProcess(c.Process, s.KillTimeout)
+	}
+	s.clientsMu.Lock()
+	delete(s.clients, c.ID)
+	s.clientsMu.Unlock()
+
+	return s.launchClient(ctx, session, c.sessionClient())
+}
+
+// openClient sends an open message to a client and waits for the reply.
+// The client's project path is the session directory joined with
+// the client's name and ID, like Non Session Manager does.
+func (s *Server) openClient(ctx context.Context, session Session, c Client) error {
+	if _, err := s.requestClient(ctx, c, osc.Message{
+		Address: nsm.AddressClientOpen,
+		Arguments: osc.Arguments{
+			osc.String(filepath.Join(session.Path, c.Name+"."+c.ID)),
+			osc.String(session.Name),
+			osc.String(c.ID),
+		},
+	}); err != nil {
+		return err
+	}
+	s.clientsMu.Lock()
+	if existing, ok := s.clients[c.ID]; ok {
+		existing.Session = session.Name
+	}
+	s.clientsMu.Unlock()
+
+	return nil
+}
+
+// waitForAnnounce waits for the client with the provided ID to announce itself.
+func (s *Server) waitForAnnounce(ctx context.Context, clientID string) (Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	for {
+		s.clientsMu.RLock()
+		c, ok := s.clients[clientID]
+		announced := s.announced
+		s.clientsMu.RUnlock()
+
+		if ok {
+			return *c, nil
+		}
+		select {
+		case <-ctx.Done():
+			return Client{}, errors.Wrap(ctx.Err(), "wait for announce")
+		case <-announced:
+		}
+	}
+}
+
+// sessionLoaded tells every client of the session that all of them have been opened.
+func (s *Server) sessionLoaded() {
+	for _, c := range s.sessionClients() {
+		_ = s.SendTo(c.Addr, osc.Message{Address: nsm.AddressClientSessionIsLoaded}) // Best effort.
+	}
+}
+
+// stopClients stops the clients of the session and every process
+// the server launched, and removes them from the registry.
+// Clients that were started outside of the server and are not part
+// of the session (e.g. controllers) stay connected.
+func (s *Server) stopClients() {
+	var (
+		done    = make(chan struct{})
+		stopped = []Client{}
+	)
+	for _, c := range s.Clients() {
+		if c.Session != "" || c.Process != nil {
+			stopped = append(stopped, c)
+		}
+	}
+	for _, c := range stopped {
+		go func(c Client) {
+			if c.Process != nil {
+				s.stopProcess(c.Process, s.KillTimeout)
+			}
+			done <- struct{}{}
+		}(c)
+	}
+	for range stopped {
+		<-done
+	}
+	s.clientsMu.Lock()
+	for _, c := range stopped {
+		delete(s.clients, c.ID)
+	}
+	s.clientsMu.Unlock()
+}
+
+// stopProcess sends SIGTERM to a process and kills it
+// if it has not exited after the grace period.
+func (s *Server) stopProcess(p *Process, grace time.Duration) {
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		_ = p.Signal(os.Kill) // Best effort.
+	}
+	select {
+	case <-p.Done():
+	case <-time.After(grace):
+		_ = p.Signal(os.Kill) // Best effort.
+		<-p.Done()
+	}
+}
+
+// sessionClients returns the clients of the session that is open, ordered by ID.
+func (s *Server) sessionClients() []Client {
+	clients := []Client{}
+	for _, c := range s.Clients() {
+		if c.Session != "" {
+			clients = append(clients, c)
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ID < clients[j].ID
+	})
+	return clients
+}
+
+// handleCommand runs a session command that was sent by a client
+// and replies with the provided message or the command's error.
+// The command runs in its own goroutine since it waits for messages
+// from other clients. Errors sending the reply are ignored so that
+// a client that went away does not stop the server.
+func (s *Server) handleCommand(msg osc.Message, message string, run func(context.Context) error) error {
+	s.Go(func() error {
+		if err := run(s.ctx); err != nil {
+			code, ok := nsm.ErrorCode(err)
+			if !ok {
+				code = nsm.ErrGeneral
+			}
+			_ = s.sendError(msg.Sender, msg.Address, code, err.Error()) // Best effort.
+			return nil
+		}
+		_ = s.sendReply(msg.Sender, msg.Address, osc.String(message)) // Best effort.
+		return nil
+	})
+	return nil
+}
+
+// handleNamedCommand runs a session command whose first argument is a name,
+// e.g. a session name or the name of an executable.
+func (s *Server) handleNamedCommand(msg osc.Message, message string, run func(context.Context, string) error) error {
+	if len(msg.Arguments) < 1 {
+		return s.sendError(msg.Sender, msg.Address, nsm.ErrGeneral, "expected a name")
+	}
+	name, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return s.sendError(msg.Sender, msg.Address, nsm.ErrGeneral, "read name: "+err.Error())
+	}
+	return s.handleCommand(msg, message, func(ctx context.Context) error {
+		return run(ctx, name)
+	})
+}
@@ -0,0 +1,370 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// helperExecutable and helperSwitchExecutable are the executable names
+// that tests put in session files.
+// helperCommand runs the test binary as a client for them.
+const (
+	helperExecutable       = "nsm-test-helper"
+	helperSwitchExecutable = "nsm-test-switch-helper"
+)
+
+// helperCommand is a Config.Command that launches the test binary
+// as a client in place of the helper executables.
+func helperCommand(executable string, args ...string) *exec.Cmd {
+	switch executable {
+	case helperExecutable:
+		return exec.Command(os.Args[0], "-test.run=^TestHelperProcess$", "--", helperArg)
+	case helperSwitchExecutable:
+		return exec.Command(os.Args[0], "-test.run=^TestHelperProcess$", "--", helperSwitchArg)
+	}
+	return exec.Command(executable, args...)
+}
+
+// helperSession is the session of the helper client.
+// It creates its project directory when it is opened and
+// writes a file in it when it is saved.
+type helperSession struct {
+	testSession
+}
+
+func (s *helperSession) Open(info nsm.SessionInfo) (string, nsm.Error) {
+	if err := os.MkdirAll(info.ProjectPath, 0755); err != nil {
+		return "", nsm.NewError(nsm.ErrCreateFailed, err.Error())
+	}
+	return s.testSession.Open(info)
+}
+
+func (s *helperSession) Save() (string, nsm.Error) {
+	if err := ioutil.WriteFile(filepath.Join(s.ProjectPath, "saved"), []byte("saved"), 0644); err != nil {
+		return "", nsm.NewError(nsm.ErrGeneral, err.Error())
+	}
+	return s.testSession.Save()
+}
+
+// helperSwitchSession is the session of the helper client that can switch sessions.
+type helperSwitchSession struct {
+	helperSession
+}
+
+func (s *helperSwitchSession) Switch(prev, next nsm.SessionInfo) (string, nsm.Error) {
+	if _, err := s.Open(next); err != nil {
+		return "", err
+	}
+	return "switched", nil
+}
+
+// newSessionServer creates a server whose sessions root is a temporary directory.
+func newSessionServer(t *testing.T) *Server {
+	return newServer(t, Config{
+		SessionsRoot: t.TempDir(),
+		Command:      helperCommand,
+		Timeout:      5 * time.Second,
+	})
+}
+
+// closeSessionServer aborts the session that is open and closes the server.
+func closeSessionServer(s *Server) {
+	_ = s.AbortSession(context.Background()) // Best effort.
+	_ = s.Close()                            // Best effort.
+}
+
+// writeSession writes a session file for the provided clients.
+func writeSession(t *testing.T, s *Server, name string, clients ...nsm.SessionClient) {
+	dir := filepath.Join(s.SessionsRoot, filepath.FromSlash(name))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	sf := &nsm.SessionFile{Clients: clients}
+	if err := sf.WriteFile(filepath.Join(dir, nsm.SessionFileName)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectCode fails the test if err is not an nsm.Error with the provided code.
+func expectCode(t *testing.T, err error, expected nsm.Code) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("expected error with code %d, got nil", expected)
+	}
+	got, ok := nsm.ErrorCode(err)
+	if !ok {
+		t.Fatalf("expected nsm.Error, got %T: %s", err, err)
+	}
+	if expected != got {
+		t.Fatalf("expected code %d, got %d (%s)", expected, got, err)
+	}
+}
+
+func TestServerNoSessionOpen(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	c := newTestClient(t, s, &testSession{})
+	defer func() { _ = c.Close() }() // Best effort.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := c.ServerSave(ctx)
+	expectCode(t, err, nsm.ErrNoSessionOpen)
+
+	_, err = c.ServerClose(ctx)
+	expectCode(t, err, nsm.ErrNoSessionOpen)
+
+	_, err = c.ServerAbort(ctx)
+	expectCode(t, err, nsm.ErrNoSessionOpen)
+
+	_, err = c.ServerOpen(ctx, "does/not/exist")
+	expectCode(t, err, nsm.ErrNoSuchFile)
+}
+
+func TestServerNewSession(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	c := newTestClient(t, s, &testSession{})
+	defer func() { _ = c.Close() }() // Best effort.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	message, err := c.ServerNew(ctx, "band/gig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "Created.", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	session, ok := s.Session()
+	if !ok {
+		t.Fatal("expected a session to be open")
+	}
+	if expected, got := "band/gig", session.Name; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if _, err := nsm.ReadSessionFile(filepath.Join(session.Path, nsm.SessionFileName)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ServerNew(ctx, "band/gig")
+	expectCode(t, err, nsm.ErrCreateFailed)
+
+	_, err = c.ServerNew(ctx, "../outside")
+	expectCode(t, err, nsm.ErrCreateFailed)
+
+	// Sessions that Sessions does not list can not be created.
+	for _, name := range []string{DefaultTrashDir + "/gig", "band/gig/nested"} {
+		_, err = c.ServerNew(ctx, name)
+		expectCode(t, err, nsm.ErrCreateFailed)
+	}
+
+	if _, err := c.ServerSave(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ServerClose(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Session(); ok {
+		t.Fatal("expected no session to be open")
+	}
+	if expected, got := 1, len(s.Clients()); expected != got {
+		t.Fatalf("expected %d clients, got %d", expected, got)
+	}
+}
+
+func TestServerOpenSaveCloseSession(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	writeSession(t, s, "gig", nsm.SessionClient{
+		Name:       "helper",
+		Executable: helperExecutable,
+		ClientID:   "nABCD",
+	})
+	c := newTestClient(t, s, &testSession{})
+	defer func() { _ = c.Close() }() // Best effort.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	message, err := c.ServerOpen(ctx, "gig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "Loaded.", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	projectPath := filepath.Join(s.SessionsRoot, "gig", "helper.nABCD")
+
+	if _, err := os.Stat(projectPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ServerSave(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(projectPath, "saved")); err != nil {
+		t.Fatal(err)
+	}
+	sf, err := nsm.ReadSessionFile(filepath.Join(s.SessionsRoot, "gig", nsm.SessionFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 1, len(sf.Clients); expected != got {
+		t.Fatalf("expected %d clients, got %d", expected, got)
+	}
+	if expected, got := (nsm.SessionClient{Name: "helper", Executable: helperExecutable, ClientID: "nABCD"}), sf.Clients[0]; expected != got {
+		t.Fatalf("expected %#v, got %#v", expected, got)
+	}
+	if _, err := c.ServerClose(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, client := range s.Clients() {
+		if client.ID == "nABCD" {
+			t.Fatal("expected helper client to be stopped")
+		}
+	}
+}
+
+// progressSession takes longer than the server's timeout to save,
+// and reports its progress while it saves.
+type progressSession struct {
+	testSession
+
+	duration time.Duration
+}
+
+// Open does not record the SessionInfo, which the client reads concurrently.
+func (s *progressSession) Open(info nsm.SessionInfo) (string, nsm.Error) {
+	return "opened", nil
+}
+
+func (s *progressSession) SaveProgress(ctx context.Context, progress *nsm.Progress) (string, nsm.Error) {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	done := time.After(s.duration)
+	for {
+		select {
+		case <-done:
+			return "saved", nil
+		case <-ctx.Done():
+			return "", nsm.NewError(nsm.ErrGeneral, "save cancelled")
+		case <-ticker.C:
+			_ = progress.Report(0.5) // Best effort.
+		}
+	}
+}
+
+func TestServerRequestClientProgress(t *testing.T) {
+	s := newServer(t, Config{Timeout: 200 * time.Millisecond})
+	defer func() { _ = s.Close() }() // Best effort.
+
+	c, err := nsm.NewClient(context.Background(), nsm.ClientConfig{
+		Name:                 "test_client",
+		Capabilities:         nsm.Capabilities{nsm.CapClientProgress},
+		Major:                APIMajor,
+		Minor:                APIMinor,
+		PID:                  os.Getpid(),
+		Session:              &progressSession{duration: 600 * time.Millisecond},
+		NsmURL:               s.URL(),
+		WaitForAnnounceReply: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }() // Best effort.
+
+	clients := s.Clients()
+	if expected, got := 1, len(clients); expected != got {
+		t.Fatalf("expected %d clients, got %d", expected, got)
+	}
+	session := Session{Name: "gig", Path: t.TempDir()}
+
+	if err := s.openClient(context.Background(), session, clients[0]); err != nil {
+		t.Fatal(err)
+	}
+	message, err := s.requestClient(context.Background(), clients[0], osc.Message{Address: nsm.AddressClientSave})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "saved", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestServerAbortSession(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	writeSession(t, s, "gig", nsm.SessionClient{
+		Name:       "helper",
+		Executable: helperExecutable,
+		ClientID:   "nABCD",
+	})
+	c := newTestClient(t, s, &testSession{})
+	defer func() { _ = c.Close() }() // Best effort.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if _, err := c.ServerOpen(ctx, "gig"); err != nil {
+		t.Fatal(err)
+	}
+	message, err := c.ServerAbort(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "Aborted.", message; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if _, err := os.Stat(filepath.Join(s.SessionsRoot, "gig", "helper.nABCD", "saved")); !os.IsNotExist(err) {
+		t.Fatalf("expected aborted session not to be saved, got %v", err)
+	}
+	if _, ok := s.Session(); ok {
+		t.Fatal("expected no session to be open")
+	}
+}
+
+func TestServerOpenSessionLaunchFailed(t *testing.T) {
+	s := newSessionServer(t)
+	defer closeSessionServer(s)
+
+	writeSession(t, s, "gig", nsm.SessionClient{
+		Name:       "missing",
+		Executable: "/this/executable/does/not/exist",
+		ClientID:   "nWXYZ",
+	})
+	err := s.OpenSession(context.Background(), "gig")
+	expectCode(t, err, nsm.ErrGeneral)
+
+	if _, ok := s.Session(); !ok {
+		t.Fatal("expected the session to be open")
+	}
+	// Clients that failed to launch stay in the session.
+	if err := s.SaveSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sf, err := nsm.ReadSessionFile(filepath.Join(s.SessionsRoot, "gig", nsm.SessionFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 1, len(sf.Clients); expected != got {
+		t.Fatalf("expected %d clients, got %d", expected, got)
+	}
+	if expected, got := "nWXYZ", sf.Clients[0].ClientID; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
@@ -1,31 +1,31 @@
 package nsm
 
 import (
+	"context"
 	"net"
 
 	"github.com/scgolang/osc"
 )
 
-// Session represents the behavior of a client
+// SessionHandler represents the behavior of a client
 // with respect to the control messages that are
 // sent by Non Session Manager.
-// The only methods in this interface that are actually
-// REQUIRED by all implementations are Open and Save.
-// The other methods are all optional and mostly depend on
-// the capabilities that a given client wishes to implement
-// (e.g. progress reports for long-running open and save operations).
-type Session interface {
-	// Announce will be called when the server has replied
-	// to the client's announce message.
-	// The server sends back information about itself in the reply.
-	// This method is optional if your Session implementation embeds SessionInfo.
-	// The SessionInfo implementation automatically assigns the ServerInfo
-	// passed to Announce as the Server field on the your struct.
-	Announce(ServerInfo) error
-
+// Open and Save are the only methods that are REQUIRED.
+// Optional behavior is discovered by checking which of the
+// small interfaces in this package (ContextOpener, ContextSaver, Switcher,
+// ProgressOpener, ProgressSaver, Announcer, Loader, GUIController,
+// MethodProvider, MessageObserver, DirtyNotifier, GUINotifier,
+// ProgressNotifier, StatusNotifier, BroadcastReceiver, ServerWatcher,
+// Cleaner) a SessionHandler also implements, and the client's
+// capabilities can be inferred from them (see InferCapabilities).
+// The client never runs two Open or Save calls at the same time; an open
+// or save that arrives while one is running is rejected with ErrNotNow,
+// and a save that arrives before a session is open with ErrNoSessionOpen.
+type SessionHandler interface {
 	// Open tells the client to open a session.
-	// If a client has not specified CapSwitch in their
-	// capabilities then this method will only be called once.
+	// If a client has not specified CapClientSwitch in their
+	// capabilities then this method will only be called once,
+	// and any further open messages are rejected with ErrGeneral.
 	Open(SessionInfo) (string, Error)
 
 	// Save will only be called after Open has been
@@ -33,48 +33,191 @@ type Session interface {
 	// a session. If the user aborts the session this method
 	// will not be called.
 	Save() (string, Error)
+}
+
+// ContextOpener is implemented by handlers whose open operation can be cancelled.
+// If a SessionHandler implements ContextOpener then OpenContext is called instead of Open.
+// The context is cancelled when the client is closed, when the context
+// passed to NewClient is done, or when the session manager goes away
+// if ClientConfig.AbortOnServerLost is set.
+type ContextOpener interface {
+	OpenContext(context.Context, SessionInfo) (string, Error)
+}
+
+// ContextSaver is implemented by handlers whose save operation can be cancelled.
+// If a SessionHandler implements ContextSaver then SaveContext is called instead of Save.
+// The context is cancelled when the client is closed, when the context
+// passed to NewClient is done, or when the session manager goes away
+// if ClientConfig.AbortOnServerLost is set.
+type ContextSaver interface {
+	SaveContext(context.Context) (string, Error)
+}
+
+// Switcher is implemented by handlers that can switch to another session
+// without being restarted. If a SessionHandler implements Switcher then
+// Switch is called instead of Open for every open message after the first one,
+// with the SessionInfo of the session that is open and the one to open.
+// The handler should save (if it wants to), tear down the previous session
+// and open the one at the new ProjectPath.
+// Implementing it implies CapClientSwitch.
+type Switcher interface {
+	Switch(prev, next SessionInfo) (string, Error)
+}
+
+// ProgressOpener is implemented by handlers with long-running open operations.
+// If a SessionHandler implements ProgressOpener then OpenProgress is called
+// instead of Open, and the reply is sent to the session manager when it returns.
+// The operation should use the Progress to report how far it has got.
+// Implementing it implies CapClientProgress.
+type ProgressOpener interface {
+	OpenProgress(context.Context, SessionInfo, *Progress) (string, Error)
+}
+
+// ProgressSaver is implemented by handlers with long-running save operations.
+// If a SessionHandler implements ProgressSaver then SaveProgress is called
+// instead of Save, and the reply is sent to the session manager when it returns.
+// The operation should use the Progress to report how far it has got.
+// Implementing it implies CapClientProgress.
+type ProgressSaver interface {
+	SaveProgress(context.Context, *Progress) (string, Error)
+}
+
+// Announcer is implemented by handlers that want to know about the server.
+type Announcer interface {
+	// Announce will be called when the server has replied
+	// to the client's announce message.
+	// The server sends back information about itself in the reply.
+	Announce(ServerInfo) error
+}
 
+// Loader is implemented by handlers that want to know when the session is loaded.
+type Loader interface {
 	// IsLoaded will be invoked when Non Session Manager
 	// has started all the clients for a given session.
 	IsLoaded() error
+}
 
-	// ShowGUI is an optional method that may be invoked in response
-	// to server commands. If your nsm client has the CapGUI capability
-	// then it should show/hide the GUI based on the bool parameter.
+// GUIController is implemented by handlers that have an optional GUI.
+// Implementing it implies CapGUI.
+type GUIController interface {
+	// ShowGUI may be invoked in response to server commands.
+	// The client should show/hide the GUI based on the bool parameter.
 	ShowGUI(bool) error
+}
+
+// MethodProvider is implemented by handlers that want to add their
+// own methods to the OSC server that is used to listen for messages
+// from Non Session Manager.
+type MethodProvider interface {
+	// Methods returns the methods to add to the OSC server.
+	// Clients who do not need to listen for OSC messages should return nil.
+	// Methods whose address begins with /nsm (see AddressPrefix) or that
+	// conflict with the client's own methods or the Handler's broadcasts
+	// make NewClient fail, since they could damage the communication between
+	// the Non Session Manager and the client's application.
+	// Use MessageObserver to see the messages the client handles itself.
+	Methods() osc.Dispatcher
+}
 
-	// Dirty is an optional client to server informational message
-	// that tells Non Session Manager that a client has unsaved changes.
-	// If your client does not support CapDirty then return nil from
-	// this method.
+// MessageObserver is implemented by handlers that want to see the
+// messages the client handles itself (e.g. /nsm/client/open or /reply)
+// without replacing the client's handling of them.
+type MessageObserver interface {
+	// Observers returns funcs that are called after the client has
+	// handled a message, keyed by the address of a built-in method.
+	// Any other address makes NewClient fail with ErrNotBuiltin.
+	Observers() osc.Dispatcher
+}
+
+// DirtyNotifier is implemented by handlers that tell Non Session Manager
+// when they have unsaved changes.
+// Implementing it implies CapClientDirty.
+type DirtyNotifier interface {
+	// Dirty returns a channel that is used to notify Non Session Manager
+	// that the client has unsaved changes (true) or not (false).
 	Dirty() chan bool
+}
 
+// GUINotifier is implemented by handlers that tell Non Session Manager
+// when their GUI is shown or hidden.
+type GUINotifier interface {
 	// GUIShowing should return a channel that is used to notify
 	// Non Session Manager that the client's GUI is hidden (false)
-	// or showing (true). If your client has the CapGUI capability
-	// then it must implement this method.
+	// or showing (true).
 	GUIShowing() chan bool
+}
 
-	// Progress can be used by clients with the CapProgress capability
-	// to indicate an ongoing save or open operation.
-	// Note that clients with CapProgress are still required to call
-	// Open or Save when the operation has completed.
+// ProgressNotifier is implemented by handlers that report the progress
+// of long-running open and save operations.
+// Implementing it implies CapClientProgress.
+type ProgressNotifier interface {
+	// Progress returns a channel that is used to indicate
+	// an ongoing save or open operation.
+	// Note that clients with CapProgress are still required to return
+	// from Open or Save when the operation has completed.
 	// The float32 that is sent on this channel must be between 0 and 1,
 	// with 1 indicating completion.
 	Progress() chan float32
+}
 
-	// ClientStatus can be used by clients with the CapMessage capability
+// StatusNotifier is implemented by handlers that send status messages
+// to Non Session Manager.
+// Implementing it implies CapClientMessage.
+type StatusNotifier interface {
+	// ClientStatus returns a channel that is used
 	// to send status updates to Non Session Manager.
 	ClientStatus() chan ClientStatus
+}
 
-	// Methods is an optional method that should be used by clients
-	// who wish to add their own methods to the OSC server that
-	// is used to listen for messages from Non Session Manager.
-	// Clients who do not need to listen for OSC messages should return nil.
-	// Note that client code should NEVER add a method whose address begins
-	// with /nsm since this could damage the communication between
-	// the Non Session Manager and the client's application.
-	Methods() osc.Dispatcher
+// Session is a SessionHandler that implements every optional
+// interface that is used to communicate with Non Session Manager.
+// Session predates SessionHandler and is still accepted by
+// ClientConfig.Session. Implementations that embed SessionInfo
+// get no-op implementations of all the optional methods.
+// Since a Session implements every optional interface the
+// capabilities of a client with a Session are never inferred.
+type Session interface {
+	SessionHandler
+	Announcer
+	Loader
+	GUIController
+	DirtyNotifier
+	GUINotifier
+	ProgressNotifier
+	StatusNotifier
+	MethodProvider
+}
+
+// BroadcastReceiver is an optional interface that can be implemented
+// by a SessionHandler to receive the messages other clients broadcast
+// through the session manager (see Client.Broadcast).
+type BroadcastReceiver interface {
+	// Broadcasts returns the handlers for the broadcast messages
+	// the session wants to receive, keyed by OSC address.
+	// The same rules as for MethodProvider apply to the addresses.
+	Broadcasts() osc.Dispatcher
+}
+
+// ServerWatcher is an optional interface that can be implemented
+// by a SessionHandler to be notified when the session manager goes away
+// and comes back (see ClientConfig.PingInterval).
+type ServerWatcher interface {
+	// ServerLost is called when the session manager stops replying.
+	ServerLost() error
+
+	// ServerRestored is called after the client has announced
+	// itself to a session manager that is replying again.
+	ServerRestored(ServerInfo) error
+}
+
+// Cleaner is an optional interface that can be implemented by a SessionHandler
+// that needs to release resources when the client is closed,
+// e.g. because the process received SIGTERM (see Client.HandleSignals).
+type Cleaner interface {
+	// Cleanup is called after ongoing open and save operations have finished.
+	// It is not called if they do not finish within the client's Timeout.
+	// The session must not be saved.
+	Cleanup() error
 }
 
 // SessionInfo contains the data a client receives
@@ -86,6 +229,9 @@ type Session interface {
 // your app should probably cache info about the current session)
 // you can easily avoid writing boilerplate no-op methods
 // for capabilities you do not wish to implement.
+// SessionHandler implementations that rely on capability inference
+// should not embed SessionInfo, since that makes them implement
+// every optional interface.
 type SessionInfo struct {
 	// ProjectPath is the path where a client can store
 	// their project-specific data.
@@ -0,0 +1,144 @@
+package nsm
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SessionFileName is the name of the file that lists the clients in a session.
+const SessionFileName = "session.nsm"
+
+// SessionFileSep is the separator of the fields in a session file entry.
+const SessionFileSep = ":"
+
+// SessionClient is an entry in a session file.
+type SessionClient struct {
+	// Name is the name of the client as displayed in the session manager.
+	Name string
+
+	// Executable is the executable that is launched to start the client.
+	Executable string
+
+	// ClientID is the ID the session manager assigned to the client.
+	ClientID string
+}
+
+// SessionFile represents the contents of a session.nsm file.
+// Each line of the file describes a client in the form name:executable:clientID.
+type SessionFile struct {
+	Clients []SessionClient
+}
+
+// ParseSessionFile parses a session file.
+// Blank lines are ignored, as are any fields after the client ID.
+func ParseSessionFile(r io.Reader) (*SessionFile, error) {
+	var (
+		sf      = &SessionFile{Clients: []SessionClient{}}
+		scanner = bufio.NewScanner(r)
+		lineno  = 0
+	)
+	for scanner.Scan() {
+		lineno++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, SessionFileSep)
+		if len(fields) < 3 {
+			return nil, errors.Errorf("line %d: expected name%sexecutable%sclientID, got %q", lineno, SessionFileSep, SessionFileSep, line)
+		}
+		sf.Clients = append(sf.Clients, SessionClient{
+			Name:       fields[0],
+			Executable: fields[1],
+			ClientID:   fields[2],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read session file")
+	}
+	return sf, nil
+}
+
+// ReadSessionFile reads the session file at the provided path.
+func ReadSessionFile(path string) (*SessionFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open session file")
+	}
+	defer func() { _ = f.Close() }() // Best effort.
+
+	return ParseSessionFile(f)
+}
+
+// Write writes the session file to w.
+func (sf *SessionFile) Write(w io.Writer) error {
+	for _, c := range sf.Clients {
+		for _, field := range []string{c.Name, c.Executable, c.ClientID} {
+			if strings.ContainsAny(field, SessionFileSep+"\r\n") {
+				return errors.Errorf("client %s: invalid field %q", c.ClientID, field)
+			}
+		}
+		if _, err := io.WriteString(w, strings.Join([]string{c.Name, c.Executable, c.ClientID}, SessionFileSep)+"\n"); err != nil {
+			return errors.Wrap(err, "write session file")
+		}
+	}
+	return nil
+}
+
+// WriteFile writes the session file to the provided path.
+// The file is written to a temporary file first so that
+// a failed write never leaves a truncated session behind.
+// The mode of an existing file is kept, and new files get mode 0644.
+func (sf *SessionFile) WriteFile(path string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "create session file")
+	}
+	if err := f.Chmod(mode); err != nil {
+		_ = f.Close()           // Best effort.
+		_ = os.Remove(f.Name()) // Best effort.
+		return errors.Wrap(err, "set session file mode")
+	}
+	if err := sf.Write(f); err != nil {
+		_ = f.Close()           // Best effort.
+		_ = os.Remove(f.Name()) // Best effort.
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name()) // Best effort.
+		return errors.Wrap(err, "close session file")
+	}
+	return errors.Wrap(os.Rename(f.Name(), path), "rename session file")
+}
+
+// Client returns the client with the provided client ID.
+func (sf *SessionFile) Client(clientID string) (SessionClient, bool) {
+	for _, c := range sf.Clients {
+		if c.ClientID == clientID {
+			return c, true
+		}
+	}
+	return SessionClient{}, false
+}
+
+// Remove removes the client with the provided client ID.
+// It returns false if there is no such client.
+func (sf *SessionFile) Remove(clientID string) bool {
+	for i, c := range sf.Clients {
+		if c.ClientID == clientID {
+			sf.Clients = append(sf.Clients[:i], sf.Clients[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package nsm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseSessionFile(t *testing.T) {
+	for i, testcase := range []struct {
+		input    string
+		expected []SessionClient
+	}{
+		{
+			input:    "",
+			expected: []SessionClient{},
+		},
+		{
+			input: "Carla:carla:nABCD\n\n  \nsooperlooper:slgui:nEFGH:extra:fields\r\n",
+			expected: []SessionClient{
+				{Name: "Carla", Executable: "carla", ClientID: "nABCD"},
+				{Name: "sooperlooper", Executable: "slgui", ClientID: "nEFGH"},
+			},
+		},
+	} {
+		sf, err := ParseSessionFile(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase.expected, sf.Clients; !reflect.DeepEqual(expected, got) {
+			t.Fatalf("(testcase %d) expected %+v, got %+v", i, expected, got)
+		}
+	}
+}
+
+func TestParseSessionFileError(t *testing.T) {
+	_, err := ParseSessionFile(strings.NewReader("Carla:carla:nABCD\nbroken:line\n"))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if expected, got := `line 2: expected name:executable:clientID, got "broken:line"`, err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestSessionFileWrite(t *testing.T) {
+	sf := &SessionFile{
+		Clients: []SessionClient{
+			{Name: "Carla", Executable: "carla", ClientID: "nABCD"},
+			{Name: "sooperlooper", Executable: "slgui", ClientID: "nEFGH"},
+		},
+	}
+	buf := &bytes.Buffer{}
+	if err := sf.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "Carla:carla:nABCD\nsooperlooper:slgui:nEFGH\n", buf.String(); expected != got {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	bad := &SessionFile{Clients: []SessionClient{{Name: "a:b", Executable: "c", ClientID: "nABCD"}}}
+	if err := bad.Write(&bytes.Buffer{}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestSessionFileRoundTrip(t *testing.T) {
+	var (
+		path = filepath.Join(t.TempDir(), SessionFileName)
+		sf   = &SessionFile{
+			Clients: []SessionClient{
+				{Name: "Carla", Executable: "carla", ClientID: "nABCD"},
+				{Name: "sooperlooper", Executable: "slgui", ClientID: "nEFGH"},
+			},
+		}
+	)
+	if err := sf.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSessionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sf, got) {
+		t.Fatalf("expected %+v, got %+v", sf, got)
+	}
+	if _, ok := got.Client("nEFGH"); !ok {
+		t.Fatal("expected to find client nEFGH")
+	}
+	if !got.Remove("nABCD") {
+		t.Fatal("expected to remove client nABCD")
+	}
+	if got.Remove("nABCD") {
+		t.Fatal("expected client nABCD to be gone")
+	}
+	if expected, got := 1, len(got.Clients); expected != got {
+		t.Fatalf("expected %d clients, got %d", expected, got)
+	}
+}
+
+func TestSessionFileWriteFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not supported on windows")
+	}
+	var (
+		path = filepath.Join(t.TempDir(), SessionFileName)
+		sf   = &SessionFile{Clients: []SessionClient{}}
+	)
+	if err := sf.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	expectMode(t, path, 0644)
+
+	if err := os.Chmod(path, 0664); err != nil {
+		t.Fatal(err)
+	}
+	if err := sf.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	expectMode(t, path, 0664)
+}
+
+// expectMode fails the test if the file at path does not have the provided permissions.
+func expectMode(t *testing.T, path string, expected os.FileMode) {
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,75 @@
+package nsm
+
+import (
+	"testing"
+
+	"github.com/scgolang/osc"
+)
+
+// minimalHandler only implements the required methods.
+type minimalHandler struct {
+	info SessionInfo
+}
+
+func (h *minimalHandler) Open(info SessionInfo) (string, Error) {
+	h.info = info
+	return "opened", nil
+}
+
+func (h *minimalHandler) Save() (string, Error) {
+	return "saved", nil
+}
+
+func TestClientHandler(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	config := testConfig()
+	config.Session = nil
+	config.Capabilities = nil
+	config.Handler = &minimalHandler{}
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if expected, got := 0, len(c.Capabilities); expected != got {
+		t.Fatalf("expected %d capabilities, got %s", expected, c.Capabilities)
+	}
+
+	// Messages for optional interfaces the handler does not implement are ignored.
+	nsmd.ShowOptionalGUI()
+	nsmd.SessionLoaded()
+
+	reply := nsmd.OpenSession(osc.Message{
+		Address: AddressClientOpen,
+		Arguments: osc.Arguments{
+			osc.String("./test-projects"),
+			osc.String("display_name"),
+			osc.String("client_id"),
+		},
+	})
+	replyMessage, err := reply.Arguments[1].ReadString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `opened`, replyMessage; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestClientSessionCapabilitiesNotInferred(t *testing.T) {
+	// mockNsmd sets an environment variable to point the client to it's listening address
+	nsmd := newMockNsmd(t, mockNsmdConfig{listenAddr: "127.0.0.1:0"})
+	defer func() { _ = nsmd.Close() }() // Best effort.
+
+	config := testConfig()
+	config.Capabilities = nil
+
+	c := newClient(t, config)
+	defer func() { _ = c.Close() }() // Best effort.
+
+	if expected, got := 0, len(c.Capabilities); expected != got {
+		t.Fatalf("expected %d capabilities, got %s", expected, c.Capabilities)
+	}
+}
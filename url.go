@@ -0,0 +1,74 @@
+package nsm
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// URLScheme is the scheme of NSM URLs.
+const URLScheme = "osc.udp"
+
+// URL is the address of a session manager, as found in NSM_URL,
+// e.g. osc.udp://localhost:12345/ or osc.udp://[::1]:12345/.
+type URL struct {
+	// Host is a hostname or an IP address.
+	// IPv6 addresses are not enclosed in brackets.
+	Host string
+	Port int
+}
+
+// ParseURL parses an NSM URL.
+// The scheme must be osc.udp and the path, if any, must be "/".
+// For compatibility a plain host:port is also accepted.
+func ParseURL(s string) (URL, error) {
+	if !strings.Contains(s, "://") {
+		return parseHostPort(s)
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return URL{}, errors.Wrap(err, "parse url")
+	}
+	if u.Scheme != URLScheme {
+		return URL{}, errors.Errorf("unsupported scheme %q, expected %s", u.Scheme, URLScheme)
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" || (u.Path != "" && u.Path != "/") {
+		return URL{}, errors.Errorf("unexpected path, query or user info in %s", s)
+	}
+	return parseHostPort(u.Host)
+}
+
+// parseHostPort parses the host:port part of an NSM URL.
+func parseHostPort(hostport string) (URL, error) {
+	host, portStr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return URL{}, errors.Wrap(err, "split host and port")
+	}
+	if host == "" {
+		return URL{}, errors.Errorf("missing host in %s", hostport)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return URL{}, errors.Errorf("invalid port %q", portStr)
+	}
+	return URL{Host: host, Port: port}, nil
+}
+
+// URLFromAddr returns the NSM URL of a network address, e.g. the
+// address a session manager is listening on.
+func URLFromAddr(addr net.Addr) (URL, error) {
+	return parseHostPort(addr.String())
+}
+
+// HostPort returns the host and port of the URL, suitable for net.ResolveUDPAddr.
+func (u URL) HostPort() string {
+	return net.JoinHostPort(u.Host, strconv.Itoa(u.Port))
+}
+
+// String formats the URL, e.g. osc.udp://[::1]:12345/.
+func (u URL) String() string {
+	return URLScheme + "://" + u.HostPort() + "/"
+}
@@ -0,0 +1,103 @@
+package nsm
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseURL(t *testing.T) {
+	for i, testcase := range []struct {
+		input    string
+		expected URL
+		str      string
+	}{
+		{
+			input:    "osc.udp://localhost:12345/",
+			expected: URL{Host: "localhost", Port: 12345},
+			str:      "osc.udp://localhost:12345/",
+		},
+		{
+			input:    "osc.udp://127.0.0.1:12345",
+			expected: URL{Host: "127.0.0.1", Port: 12345},
+			str:      "osc.udp://127.0.0.1:12345/",
+		},
+		{
+			input:    "osc.udp://[::1]:1234/",
+			expected: URL{Host: "::1", Port: 1234},
+			str:      "osc.udp://[::1]:1234/",
+		},
+		{
+			input:    "OSC.UDP://studio.local:7000/",
+			expected: URL{Host: "studio.local", Port: 7000},
+			str:      "osc.udp://studio.local:7000/",
+		},
+		{
+			input:    "127.0.0.1:5000",
+			expected: URL{Host: "127.0.0.1", Port: 5000},
+			str:      "osc.udp://127.0.0.1:5000/",
+		},
+	} {
+		u, err := ParseURL(testcase.input)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase.expected, u; expected != got {
+			t.Fatalf("(testcase %d) expected %+v, got %+v", i, expected, got)
+		}
+		if expected, got := testcase.str, u.String(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestParseURLErrors(t *testing.T) {
+	for i, testcase := range []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    "osc.tcp://localhost:12345/",
+			expected: `unsupported scheme "osc.tcp", expected osc.udp`,
+		},
+		{
+			input:    "osc.udp://localhost:12345/foo",
+			expected: `unexpected path, query or user info in osc.udp://localhost:12345/foo`,
+		},
+		{
+			input:    "osc.udp://localhost/",
+			expected: `split host and port: `,
+		},
+		{
+			input:    "osc.udp://:12345/",
+			expected: `missing host in :12345`,
+		},
+		{
+			input:    "osc.udp://localhost:99999/",
+			expected: `invalid port "99999"`,
+		},
+		{
+			input:    "osc.udp://localhost:abc/",
+			expected: `parse url: `,
+		},
+	} {
+		_, err := ParseURL(testcase.input)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		// Messages from the standard library are only checked by prefix.
+		if expected, got := testcase.expected, err.Error(); !strings.HasPrefix(got, expected) {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestURLFromAddr(t *testing.T) {
+	u, err := URLFromAddr(&net.UDPAddr{IP: net.ParseIP("::1"), Port: 4000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "osc.udp://[::1]:4000/", u.String(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}